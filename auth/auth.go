@@ -3,25 +3,37 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"math/big"
 	"time"
 )
 
 type User struct {
-	ID       int32
-	Password string
+	ID int32
+	// Password is never sent to clients.
+	Password string `json:"-"`
 	Username string
 }
 
 type Session struct {
-	ID           string
+	// ID is the hash of the token, which is all that is stored.
+	ID string
+	// Token is the secret the client authenticates with. It is only known
+	// when the session is created or authenticated.
+	Token string
+	// PublicID identifies the session to its user without revealing the session ID.
+	PublicID     string
 	User         User
 	CreatedAt    time.Time
 	RevokedAt    time.Time
 	ExpiresAt    time.Time
 	LastActiveAt time.Time
 	IsRevoked    bool
+	UserAgent    string
+	IP           string
+	DeviceLabel  string
 }
 
 type UserWithPassword struct {
@@ -32,8 +44,26 @@ type UserWithPassword struct {
 type AuthRepository interface {
 	GetUserByUsername(ctx context.Context, username string) (UserWithPassword, error)
 	AddUser(ctx context.Context, params UserWithPassword) error
+	UpdateUserPassword(ctx context.Context, userID int32, password string) error
+}
+
+// SessionStore stores sessions separately from users, so sessions can be cached
+// or kept somewhere faster.
+type SessionStore interface {
+	// GetSession finds a session by its ID, the hash of its token.
 	GetSession(ctx context.Context, sessionID string) (Session, error)
 	CreateSession(ctx context.Context, session Session) error
+	// TouchSession records the last activity and the new expiry of a session.
+	TouchSession(ctx context.Context, sessionID string, lastActiveAt time.Time, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID string, revokedAt time.Time) error
+	// ListUserSessions returns the sessions of a user that are neither revoked nor expired at now.
+	ListUserSessions(ctx context.Context, userID int32, now time.Time) ([]Session, error)
+	// RevokeUserSession revokes the session of a user with the public ID.
+	// It returns false if the user has no such active session.
+	RevokeUserSession(ctx context.Context, userID int32, publicID string, revokedAt time.Time) (bool, error)
+	// RevokeUserSessions revokes all sessions of a user except exceptSessionID
+	// and returns how many were revoked.
+	RevokeUserSessions(ctx context.Context, userID int32, exceptSessionID string, revokedAt time.Time) (int64, error)
 }
 
 type PasswordCredentials struct {
@@ -41,6 +71,16 @@ type PasswordCredentials struct {
 	Password string
 }
 
+type ChangePasswordParams struct {
+	User            User
+	CurrentPassword string
+	NewPassword     string
+	// SessionID is the session the password is changed with. It is replaced
+	// by a new session. It is empty if the user didn't authenticate with a session.
+	SessionID string
+	Device    Device
+}
+
 func NewSessionID(n int) (string, error) {
 	const charset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-"
 	ret := make([]byte, n)
@@ -55,20 +95,33 @@ func NewSessionID(n int) (string, error) {
 	return string(ret), nil
 }
 
-func NewSession(user User) (*Session, error) {
-	sessionTTL := time.Hour * 24 * 30
-	sessionID, err := NewSessionID(32)
+// HashSessionToken returns the ID a session is stored under, so a leaked
+// database can't be used to log in. The tokens are random enough that a
+// plain SHA-256 can't be reversed.
+func HashSessionToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// NewSession creates a session that expires after ttl if it isn't used.
+func NewSession(user User, device Device, now time.Time, ttl time.Duration) (*Session, error) {
+	token, err := NewSessionID(32)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create session: %w", err)
 	}
 	return &Session{
-		ID:           sessionID,
+		ID:           HashSessionToken(token),
+		Token:        token,
 		User:         user,
-		CreatedAt:    time.Time{},
+		CreatedAt:    now,
 		RevokedAt:    time.Time{},
-		ExpiresAt:    time.Now().Add(sessionTTL),
-		LastActiveAt: time.Time{},
+		ExpiresAt:    now.Add(ttl),
+		LastActiveAt: now,
 		IsRevoked:    false,
+		UserAgent:    device.UserAgent,
+		IP:           device.IP,
+		DeviceLabel:  device.Label(),
 	}, nil
 }
@@ -2,7 +2,9 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -13,35 +15,102 @@ type Clock interface {
 }
 
 type AuthService struct {
-	repository AuthRepository
-	clock      Clock
+	repository           AuthRepository
+	sessions             SessionStore
+	clock                Clock
+	sessionIdleTimeout   time.Duration
+	sessionLifetime      time.Duration
+	sessionRenewalWindow time.Duration
+	sessionTouchInterval time.Duration
+	passwordPolicy       *PasswordPolicy
 }
 
 type NewAuthServiceParams struct {
 	Repository AuthRepository
+	Sessions   SessionStore
 	Clock      Clock
+	// SessionIdleTimeout logs users out after they were inactive for this long.
+	// Defaults to 7 days.
+	SessionIdleTimeout time.Duration
+	// SessionLifetime is the longest a session lasts, no matter how active it is.
+	// Defaults to 30 days.
+	SessionLifetime time.Duration
+	// SessionRenewalWindow extends sessions that are used when they expire within it.
+	// Defaults to half of the idle timeout.
+	SessionRenewalWindow time.Duration
+	// SessionTouchInterval is how often the last activity of a session is written.
+	// Defaults to a minute.
+	SessionTouchInterval time.Duration
+	// PasswordPolicy is checked when users choose a password.
+	// Defaults to a policy created with no params.
+	PasswordPolicy *PasswordPolicy
 }
 
 func NewAuthService(params NewAuthServiceParams) *AuthService {
+	if params.SessionIdleTimeout == 0 {
+		params.SessionIdleTimeout = 7 * 24 * time.Hour
+	}
+
+	if params.SessionLifetime == 0 {
+		params.SessionLifetime = 30 * 24 * time.Hour
+	}
+
+	if params.SessionRenewalWindow == 0 {
+		params.SessionRenewalWindow = params.SessionIdleTimeout / 2
+	}
+
+	if params.SessionTouchInterval == 0 {
+		params.SessionTouchInterval = time.Minute
+	}
+
+	if params.PasswordPolicy == nil {
+		params.PasswordPolicy = NewPasswordPolicy(NewPasswordPolicyParams{})
+	}
+
 	return &AuthService{
-		repository: params.Repository,
-		clock:      params.Clock,
+		repository:           params.Repository,
+		sessions:             params.Sessions,
+		clock:                params.Clock,
+		sessionIdleTimeout:   params.SessionIdleTimeout,
+		sessionLifetime:      params.SessionLifetime,
+		sessionRenewalWindow: params.SessionRenewalWindow,
+		sessionTouchInterval: params.SessionTouchInterval,
+		passwordPolicy:       params.PasswordPolicy,
 	}
 }
 
+// dummyPasswordHash is compared for unknown users, so they take as long as known ones.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+	return hash
+})
+
+// AuthenticateWithPassword returns [ErrInvalidCredentials] if the user doesn't
+// exist or the password is wrong. Both take the same time.
 func (srv *AuthService) AuthenticateWithPassword(ctx context.Context, creds PasswordCredentials) (User, error) {
 	var user User
 
-	dbUser, err := srv.repository.GetUserByUsername(ctx, creds.Username)
+	dbUser, err := srv.repository.GetUserByUsername(ctx, FoldUsername(creds.Username))
+
+	if errors.Is(err, ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(creds.Password))
+
+		return user, ErrInvalidCredentials
+	}
 
 	if err != nil {
-		return user, err
+		return user, fmt.Errorf("Failed to get user: %w", err)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(creds.Password))
 
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return user, ErrInvalidCredentials
+	}
+
 	if err != nil {
-		return user, err
+		return user, fmt.Errorf("Failed to compare password: %w", err)
 	}
 
 	user = User{
@@ -52,7 +121,22 @@ func (srv *AuthService) AuthenticateWithPassword(ctx context.Context, creds Pass
 	return user, nil
 }
 
+// Register stores the user under its normalized username. It returns [ErrInvalidUsername]
+// if the username can't be normalized, a [ValidationError] if the password breaks the
+// policy and [ErrUsernameTaken] if the username is already used.
 func (srv *AuthService) Register(ctx context.Context, creds PasswordCredentials) (User, error) {
+	username, err := NormalizeUsername(creds.Username)
+
+	if err != nil {
+		return User{}, err
+	}
+
+	err = srv.checkPassword(ctx, "password", username, creds.Password)
+
+	if err != nil {
+		return User{}, err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
 
 	if err != nil {
@@ -61,7 +145,7 @@ func (srv *AuthService) Register(ctx context.Context, creds PasswordCredentials)
 
 	err = srv.repository.AddUser(ctx, UserWithPassword{
 		User: User{
-			Username: creds.Username,
+			Username: username,
 		},
 		Password: string(hashedPassword),
 	})
@@ -71,45 +155,231 @@ func (srv *AuthService) Register(ctx context.Context, creds PasswordCredentials)
 	}
 
 	user := User{
-		Username: creds.Username,
+		Username: username,
 	}
 
 	return user, nil
 }
 
-func (srv *AuthService) AuthenticateSession(ctx context.Context, sessionID string) (User, error) {
-	session, err := srv.repository.GetSession(ctx, sessionID)
+// AuthenticateSession returns the session of the token if it is still valid,
+// [ErrSessionNotFound], [ErrSessionExpired] or [ErrSessionRevoked] otherwise. It records the
+// activity and, if the session expires soon, extends it up to its lifetime.
+// renewed is true if the expiry changed, so the client should get it again.
+func (srv *AuthService) AuthenticateSession(ctx context.Context, token string) (session Session, renewed bool, err error) {
+	session, err = srv.sessions.GetSession(ctx, HashSessionToken(token))
+
+	if errors.Is(err, ErrSessionNotFound) {
+		return Session{}, false, ErrSessionNotFound
+	}
 
 	if err != nil {
-		return User{}, fmt.Errorf("Failed to get session: %w", err)
+		return Session{}, false, fmt.Errorf("Failed to get session: %w", err)
 	}
 
+	session.Token = token
+
 	now := srv.clock.Now()
+	maxExpiresAt := session.CreatedAt.Add(srv.sessionLifetime)
+
+	if session.IsRevoked && now.After(session.RevokedAt) {
+		return Session{}, false, ErrSessionRevoked
+	}
+
+	if now.After(session.ExpiresAt) || now.After(maxExpiresAt) {
+		return Session{}, false, ErrSessionExpired
+	}
+
+	if session.ExpiresAt.Sub(now) < srv.sessionRenewalWindow {
+		expiresAt := now.Add(srv.sessionIdleTimeout)
+
+		if expiresAt.After(maxExpiresAt) {
+			expiresAt = maxExpiresAt
+		}
+
+		renewed = expiresAt.After(session.ExpiresAt)
+		session.ExpiresAt = expiresAt
+	}
+
+	if renewed || now.Sub(session.LastActiveAt) >= srv.sessionTouchInterval {
+		session.LastActiveAt = now
 
-	isRevoked := session.IsRevoked && now.After(session.RevokedAt)
-	isExpired := now.After(session.ExpiresAt)
+		err = srv.sessions.TouchSession(ctx, session.ID, session.LastActiveAt, session.ExpiresAt)
 
-	if isRevoked || isExpired {
-		return User{}, fmt.Errorf("Session expired")
+		if err != nil {
+			return Session{}, false, fmt.Errorf("Failed to touch session: %w", err)
+		}
 	}
 
-	return session.User, nil
+	return session, renewed, nil
 }
 
-func (srv *AuthService) CreateSession(ctx context.Context, user User) (*Session, error) {
-	session, err := NewSession(user)
+func (srv *AuthService) CreateSession(ctx context.Context, user User, device Device) (*Session, error) {
+	session, err := NewSession(user, device, srv.clock.Now(), srv.sessionIdleTimeout)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = srv.repository.CreateSession(ctx, *session)
+	err = srv.sessions.CreateSession(ctx, *session)
 
 	if err != nil {
 		return nil, err
 	}
 
-	createdSession, err := srv.repository.GetSession(ctx, session.ID)
+	createdSession, err := srv.sessions.GetSession(ctx, session.ID)
+	createdSession.Token = session.Token
 
 	return &createdSession, err
 }
+
+// RotateSession replaces a session with a new one, so a session token that
+// leaked or was planted before can't be used anymore. Call it whenever the
+// privileges of a session change, e.g. on login or when roles change.
+// The old session is only revoked if it belongs to user, otherwise anyone
+// could log others out by sending their token.
+func (srv *AuthService) RotateSession(ctx context.Context, sessionID string, user User, device Device) (*Session, error) {
+	previous, err := srv.sessions.GetSession(ctx, sessionID)
+
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return nil, fmt.Errorf("Failed to get session: %w", err)
+	}
+
+	owned := err == nil && previous.User.ID == user.ID
+
+	session, err := srv.CreateSession(ctx, user, device)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !owned {
+		return session, nil
+	}
+
+	err = srv.RevokeSession(ctx, sessionID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// ChangePassword sets a new password and logs the user out everywhere. If the
+// password was changed with a session, it returns a new session to replace it.
+func (srv *AuthService) ChangePassword(ctx context.Context, params ChangePasswordParams) (*Session, error) {
+	_, err := srv.AuthenticateWithPassword(ctx, PasswordCredentials{
+		Username: params.User.Username,
+		Password: params.CurrentPassword,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = srv.checkPassword(ctx, "new_password", params.User.Username, params.NewPassword)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.NewPassword), bcrypt.DefaultCost)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = srv.repository.UpdateUserPassword(ctx, params.User.ID, string(hashedPassword))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to update password: %w", err)
+	}
+
+	_, err = srv.RevokeAllSessions(ctx, params.User.ID, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if params.SessionID == "" {
+		return nil, nil
+	}
+
+	return srv.CreateSession(ctx, params.User, params.Device)
+}
+
+// checkPassword returns a [ValidationError] for field if the password breaks the policy.
+func (srv *AuthService) checkPassword(ctx context.Context, field, username, password string) error {
+	violations, err := srv.passwordPolicy.Check(ctx, username, password)
+
+	if err != nil {
+		return err
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	validationErr := &ValidationError{}
+
+	for _, violation := range violations {
+		validationErr.Errors = append(validationErr.Errors, FieldError{
+			Field:   field,
+			Code:    violation.Code,
+			Message: violation.Message,
+		})
+	}
+
+	return validationErr
+}
+
+// RevokeSession logs the session out. It can't be used anymore, even if it
+// was stolen.
+func (srv *AuthService) RevokeSession(ctx context.Context, sessionID string) error {
+	err := srv.sessions.RevokeSession(ctx, sessionID, srv.clock.Now())
+
+	if err != nil {
+		return fmt.Errorf("Failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAllSessions logs the user out everywhere, except for the session
+// exceptCurrent. It is empty to revoke every session.
+func (srv *AuthService) RevokeAllSessions(ctx context.Context, userID int32, exceptCurrent string) (int64, error) {
+	revoked, err := srv.sessions.RevokeUserSessions(ctx, userID, exceptCurrent, srv.clock.Now())
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to revoke sessions: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// ListSessions returns where the user is logged in, most recently active first.
+func (srv *AuthService) ListSessions(ctx context.Context, userID int32) ([]Session, error) {
+	sessions, err := srv.sessions.ListUserSessions(ctx, userID, srv.clock.Now())
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeUserSession logs the user out of one of their sessions.
+// It returns [ErrSessionNotFound] if the user has no active session with the public ID.
+func (srv *AuthService) RevokeUserSession(ctx context.Context, userID int32, publicID string) error {
+	revoked, err := srv.sessions.RevokeUserSession(ctx, userID, publicID, srv.clock.Now())
+
+	if err != nil {
+		return fmt.Errorf("Failed to revoke session: %w", err)
+	}
+
+	if !revoked {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package auth_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/dpbrackin/ready-set-go/auth"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type mockAuthRepository struct {
@@ -33,7 +35,7 @@ func (m *mockAuthRepository) AddUser(ctx context.Context, params auth.UserWithPa
 	return args.Error(0)
 }
 
-// GetSession implements auth.AuthRepository.
+// GetSession implements auth.SessionStore.
 func (m *mockAuthRepository) GetSession(ctx context.Context, sessionID string) (auth.Session, error) {
 	args := m.Called(ctx, sessionID)
 	// Check if the returned value is of type auth.Session
@@ -57,13 +59,55 @@ func (m *mockAuthRepository) GetUserByUsername(ctx context.Context, username str
 	return user, args.Error(1)
 }
 
-// CreateSession implements auth.AuthRepository.
+// CreateSession implements auth.SessionStore.
 func (m *mockAuthRepository) CreateSession(ctx context.Context, session auth.Session) error {
 	args := m.Called(ctx, session)
 
 	return args.Error(0)
 }
 
+// TouchSession implements auth.SessionStore.
+func (m *mockAuthRepository) TouchSession(ctx context.Context, sessionID string, lastActiveAt time.Time, expiresAt time.Time) error {
+	args := m.Called(ctx, sessionID, lastActiveAt, expiresAt)
+
+	return args.Error(0)
+}
+
+// ListUserSessions implements auth.SessionStore.
+func (m *mockAuthRepository) ListUserSessions(ctx context.Context, userID int32, now time.Time) ([]auth.Session, error) {
+	args := m.Called(ctx, userID, now)
+
+	return args.Get(0).([]auth.Session), args.Error(1)
+}
+
+// RevokeUserSession implements auth.SessionStore.
+func (m *mockAuthRepository) RevokeUserSession(ctx context.Context, userID int32, publicID string, revokedAt time.Time) (bool, error) {
+	args := m.Called(ctx, userID, publicID, revokedAt)
+
+	return args.Bool(0), args.Error(1)
+}
+
+// UpdateUserPassword implements auth.AuthRepository.
+func (m *mockAuthRepository) UpdateUserPassword(ctx context.Context, userID int32, password string) error {
+	args := m.Called(ctx, userID, password)
+
+	return args.Error(0)
+}
+
+// RevokeSession implements auth.SessionStore.
+func (m *mockAuthRepository) RevokeSession(ctx context.Context, sessionID string, revokedAt time.Time) error {
+	args := m.Called(ctx, sessionID, revokedAt)
+
+	return args.Error(0)
+}
+
+// RevokeUserSessions implements auth.SessionStore.
+func (m *mockAuthRepository) RevokeUserSessions(ctx context.Context, userID int32, exceptSessionID string, revokedAt time.Time) (int64, error) {
+	args := m.Called(ctx, userID, exceptSessionID, revokedAt)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func TestValidAuthenticateSession(t *testing.T) {
 	validSession := auth.Session{
 		ID: "session1",
@@ -78,22 +122,28 @@ func TestValidAuthenticateSession(t *testing.T) {
 		IsRevoked:    false,
 	}
 
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
 	repository := new(mockAuthRepository)
-	repository.On("GetSession", mock.Anything, "session1").Return(validSession, nil)
+	repository.On("GetSession", mock.Anything, auth.HashSessionToken("session1")).Return(validSession, nil)
+	repository.On("TouchSession", mock.Anything, "session1", now, validSession.ExpiresAt).Return(nil)
 
 	fakeClock := new(mockClock)
-	fakeClock.On("Now").Return(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	fakeClock.On("Now").Return(now)
 
 	service := auth.NewAuthService(auth.NewAuthServiceParams{
 		Repository: repository,
+		Sessions:   repository,
 		Clock:      fakeClock,
 	})
 
 	ctx := context.Background()
-	res, err := service.AuthenticateSession(ctx, "session1")
+	res, renewed, err := service.AuthenticateSession(ctx, "session1")
 
 	assert.Nil(t, err)
-	assert.Equal(t, validSession.User, res)
+	assert.False(t, renewed)
+	assert.Equal(t, validSession.User, res.User)
+	assert.Equal(t, now, res.LastActiveAt)
 }
 
 func TestExpiredSession(t *testing.T) {
@@ -111,20 +161,21 @@ func TestExpiredSession(t *testing.T) {
 	}
 
 	repository := new(mockAuthRepository)
-	repository.On("GetSession", mock.Anything, "session1").Return(expiredSession, nil)
+	repository.On("GetSession", mock.Anything, auth.HashSessionToken("session1")).Return(expiredSession, nil)
 
 	fakeClock := new(mockClock)
 	fakeClock.On("Now").Return(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
 
 	service := auth.NewAuthService(auth.NewAuthServiceParams{
 		Repository: repository,
+		Sessions:   repository,
 		Clock:      fakeClock,
 	})
 
 	ctx := context.Background()
-	_, err := service.AuthenticateSession(ctx, "session1")
+	_, _, err := service.AuthenticateSession(ctx, "session1")
 
-	assert.NotNil(t, err)
+	assert.ErrorIs(t, err, auth.ErrSessionExpired)
 }
 
 func TestRevokedSession(t *testing.T) {
@@ -142,18 +193,418 @@ func TestRevokedSession(t *testing.T) {
 	}
 
 	repository := new(mockAuthRepository)
-	repository.On("GetSession", mock.Anything, "session1").Return(revokedSession, nil)
+	repository.On("GetSession", mock.Anything, auth.HashSessionToken("session1")).Return(revokedSession, nil)
 
 	fakeClock := new(mockClock)
 	fakeClock.On("Now").Return(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
 
 	service := auth.NewAuthService(auth.NewAuthServiceParams{
 		Repository: repository,
+		Sessions:   repository,
 		Clock:      fakeClock,
 	})
 
 	ctx := context.Background()
-	_, err := service.AuthenticateSession(ctx, "session1")
+	_, _, err := service.AuthenticateSession(ctx, "session1")
+
+	assert.ErrorIs(t, err, auth.ErrSessionRevoked)
+}
+
+func TestAuthenticateSessionThrottlesTouch(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	session := auth.Session{
+		ID:           "session1",
+		User:         auth.User{Username: "user1", ID: 1},
+		CreatedAt:    time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		ExpiresAt:    time.Date(2025, 1, 7, 0, 0, 0, 0, time.UTC),
+		LastActiveAt: now.Add(-30 * time.Second),
+	}
+
+	repository := new(mockAuthRepository)
+	repository.On("GetSession", mock.Anything, auth.HashSessionToken("session1")).Return(session, nil)
+
+	fakeClock := new(mockClock)
+	fakeClock.On("Now").Return(now)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      fakeClock,
+	})
+
+	ctx := context.Background()
+	_, renewed, err := service.AuthenticateSession(ctx, "session1")
+
+	assert.Nil(t, err)
+	assert.False(t, renewed)
+	repository.AssertNotCalled(t, "TouchSession", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthenticateSessionRenews(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	session := auth.Session{
+		ID:           "session1",
+		User:         auth.User{Username: "user1", ID: 1},
+		CreatedAt:    time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC),
+		ExpiresAt:    now.Add(time.Hour),
+		LastActiveAt: now.Add(-time.Hour),
+	}
+	expiresAt := now.Add(7 * 24 * time.Hour)
+
+	repository := new(mockAuthRepository)
+	repository.On("GetSession", mock.Anything, auth.HashSessionToken("session1")).Return(session, nil)
+	repository.On("TouchSession", mock.Anything, "session1", now, expiresAt).Return(nil)
+
+	fakeClock := new(mockClock)
+	fakeClock.On("Now").Return(now)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      fakeClock,
+	})
+
+	ctx := context.Background()
+	res, renewed, err := service.AuthenticateSession(ctx, "session1")
+
+	assert.Nil(t, err)
+	assert.True(t, renewed)
+	assert.Equal(t, expiresAt, res.ExpiresAt)
+}
+
+func TestAuthenticateSessionRenewalStopsAtLifetime(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	session := auth.Session{
+		ID:           "session1",
+		User:         auth.User{Username: "user1", ID: 1},
+		CreatedAt:    time.Date(2024, 12, 5, 0, 0, 0, 0, time.UTC),
+		ExpiresAt:    now.Add(time.Hour),
+		LastActiveAt: now.Add(-time.Hour),
+	}
+	maxExpiresAt := session.CreatedAt.Add(30 * 24 * time.Hour)
+
+	repository := new(mockAuthRepository)
+	repository.On("GetSession", mock.Anything, auth.HashSessionToken("session1")).Return(session, nil)
+	repository.On("TouchSession", mock.Anything, "session1", now, maxExpiresAt).Return(nil)
+
+	fakeClock := new(mockClock)
+	fakeClock.On("Now").Return(now)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      fakeClock,
+	})
+
+	ctx := context.Background()
+	res, renewed, err := service.AuthenticateSession(ctx, "session1")
+
+	assert.Nil(t, err)
+	assert.True(t, renewed)
+	assert.Equal(t, maxExpiresAt, res.ExpiresAt)
+
+	fakeClock = new(mockClock)
+	fakeClock.On("Now").Return(maxExpiresAt.Add(time.Second))
+
+	service = auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      fakeClock,
+	})
+
+	_, _, err = service.AuthenticateSession(ctx, "session1")
 
 	assert.NotNil(t, err)
 }
+
+func TestUnknownSession(t *testing.T) {
+	repository := new(mockAuthRepository)
+	repository.On("GetSession", mock.Anything, auth.HashSessionToken("session1")).Return(auth.Session{}, auth.ErrSessionNotFound)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      new(mockClock),
+	})
+
+	_, _, err := service.AuthenticateSession(context.Background(), "session1")
+
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+	assert.True(t, auth.IsUnauthenticated(err))
+}
+
+func TestAuthenticateWithPasswordInvalidCredentials(t *testing.T) {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user := auth.User{ID: 1, Username: "user1"}
+
+	repository := new(mockAuthRepository)
+	repository.On("GetUserByUsername", mock.Anything, "user1").Return(auth.UserWithPassword{User: user, Password: string(hashedPassword)}, nil)
+	repository.On("GetUserByUsername", mock.Anything, "nobody").Return(auth.UserWithPassword{}, auth.ErrUserNotFound)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      new(mockClock),
+	})
+
+	ctx := context.Background()
+
+	_, err := service.AuthenticateWithPassword(ctx, auth.PasswordCredentials{Username: "user1", Password: "wrong password"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	_, err = service.AuthenticateWithPassword(ctx, auth.PasswordCredentials{Username: "nobody", Password: "password"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	assert.NotErrorIs(t, err, auth.ErrUserNotFound)
+
+	_, err = service.AuthenticateWithPassword(ctx, auth.PasswordCredentials{Username: "user1", Password: "password"})
+	assert.Nil(t, err)
+}
+
+func TestRegisterNormalizesUsername(t *testing.T) {
+	repository := new(mockAuthRepository)
+	repository.On("AddUser", mock.Anything, mock.MatchedBy(func(user auth.UserWithPassword) bool {
+		return user.Username == "alice"
+	})).Return(nil).Once()
+	repository.On("AddUser", mock.Anything, mock.Anything).Return(auth.ErrUsernameTaken)
+	repository.On("GetUserByUsername", mock.Anything, "alice").Return(auth.UserWithPassword{}, auth.ErrUserNotFound)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      new(mockClock),
+	})
+
+	ctx := context.Background()
+
+	user, err := service.Register(ctx, auth.PasswordCredentials{Username: "Alice", Password: "violet-harbor-lantern-42"})
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", user.Username)
+
+	_, err = service.Register(ctx, auth.PasswordCredentials{Username: "ALICE", Password: "violet-harbor-lantern-42"})
+	assert.ErrorIs(t, err, auth.ErrUsernameTaken)
+
+	_, err = service.Register(ctx, auth.PasswordCredentials{Username: "p\u0430ypal", Password: "violet-harbor-lantern-42"})
+	assert.ErrorIs(t, err, auth.ErrInvalidUsername)
+
+	_, err = service.AuthenticateWithPassword(ctx, auth.PasswordCredentials{Username: "ＡＬＩＣＥ", Password: "violet-harbor-lantern-42"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	repository.AssertCalled(t, "GetUserByUsername", mock.Anything, "alice")
+}
+
+func TestRegisterChecksPasswordPolicy(t *testing.T) {
+	repository := new(mockAuthRepository)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      new(mockClock),
+	})
+
+	_, err := service.Register(context.Background(), auth.PasswordCredentials{Username: "alice", Password: "alice123"})
+
+	var validationErr *auth.ValidationError
+
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "password", validationErr.Errors[0].Field)
+	assert.Equal(t, auth.PasswordContainsUsername, validationErr.Errors[0].Code)
+	repository.AssertNotCalled(t, "AddUser", mock.Anything, mock.Anything)
+}
+
+func TestAuthenticateWithPasswordRepositoryError(t *testing.T) {
+	repository := new(mockAuthRepository)
+	repository.On("GetUserByUsername", mock.Anything, "user1").Return(auth.UserWithPassword{}, errors.New("connection refused"))
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      new(mockClock),
+	})
+
+	_, err := service.AuthenticateWithPassword(context.Background(), auth.PasswordCredentials{Username: "user1", Password: "password"})
+
+	assert.NotNil(t, err)
+	assert.False(t, auth.IsUnauthenticated(err))
+}
+
+func TestRevokeSession(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	repository := new(mockAuthRepository)
+	repository.On("RevokeSession", mock.Anything, "session1", now).Return(nil)
+
+	fakeClock := new(mockClock)
+	fakeClock.On("Now").Return(now)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      fakeClock,
+	})
+
+	ctx := context.Background()
+	err := service.RevokeSession(ctx, "session1")
+
+	assert.Nil(t, err)
+	repository.AssertExpectations(t)
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	repository := new(mockAuthRepository)
+	repository.On("RevokeUserSessions", mock.Anything, int32(1), "session1", now).Return(int64(3), nil)
+
+	fakeClock := new(mockClock)
+	fakeClock.On("Now").Return(now)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      fakeClock,
+	})
+
+	ctx := context.Background()
+	revoked, err := service.RevokeAllSessions(ctx, 1, "session1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), revoked)
+}
+
+func TestRevokeUserSessionNotFound(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	repository := new(mockAuthRepository)
+	repository.On("RevokeUserSession", mock.Anything, int32(1), "public1", now).Return(false, nil)
+
+	fakeClock := new(mockClock)
+	fakeClock.On("Now").Return(now)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      fakeClock,
+	})
+
+	ctx := context.Background()
+	err := service.RevokeUserSession(ctx, 1, "public1")
+
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+}
+
+func TestCreateSessionStoresHash(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var stored auth.Session
+
+	repository := new(mockAuthRepository)
+	repository.On("CreateSession", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(auth.Session)
+	}).Return(nil)
+	repository.On("GetSession", mock.Anything, mock.Anything).Return(auth.Session{}, nil).Run(func(args mock.Arguments) {
+		assert.Equal(t, stored.ID, args.Get(1))
+	})
+
+	fakeClock := new(mockClock)
+	fakeClock.On("Now").Return(now)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      fakeClock,
+	})
+
+	ctx := context.Background()
+	session, err := service.CreateSession(ctx, auth.User{ID: 1}, auth.Device{})
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, session.Token)
+	assert.Equal(t, auth.HashSessionToken(session.Token), stored.ID)
+	assert.NotEqual(t, session.Token, stored.ID)
+}
+
+func TestRotateSessionOnlyRevokesOwnSession(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := new(mockClock)
+	fakeClock.On("Now").Return(now)
+
+	sessions := auth.NewMemorySessionStore()
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: new(mockAuthRepository),
+		Sessions:   sessions,
+		Clock:      fakeClock,
+	})
+
+	ctx := context.Background()
+	alice := auth.User{ID: 1, Username: "alice"}
+	bob := auth.User{ID: 2, Username: "bob"}
+
+	bobSession, err := service.CreateSession(ctx, bob, auth.Device{})
+	assert.Nil(t, err)
+
+	// Alice logs in with a cookie that holds Bob's token.
+	_, err = service.RotateSession(ctx, bobSession.ID, alice, auth.Device{})
+	assert.Nil(t, err)
+
+	stored, err := sessions.GetSession(ctx, bobSession.ID)
+	assert.Nil(t, err)
+	assert.False(t, stored.IsRevoked)
+
+	aliceSession, err := service.CreateSession(ctx, alice, auth.Device{})
+	assert.Nil(t, err)
+
+	rotated, err := service.RotateSession(ctx, aliceSession.ID, alice, auth.Device{})
+	assert.Nil(t, err)
+
+	stored, err = sessions.GetSession(ctx, aliceSession.ID)
+	assert.Nil(t, err)
+	assert.True(t, stored.IsRevoked)
+
+	stored, err = sessions.GetSession(ctx, rotated.ID)
+	assert.Nil(t, err)
+	assert.False(t, stored.IsRevoked)
+
+	_, err = service.RotateSession(ctx, "unknown", alice, auth.Device{})
+	assert.Nil(t, err)
+}
+
+func TestChangePasswordRotatesSession(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("old password"), bcrypt.MinCost)
+	user := auth.User{ID: 1, Username: "user1"}
+
+	repository := new(mockAuthRepository)
+	repository.On("GetUserByUsername", mock.Anything, "user1").Return(auth.UserWithPassword{User: user, Password: string(hashedPassword)}, nil)
+	repository.On("UpdateUserPassword", mock.Anything, int32(1), mock.Anything).Return(nil)
+	repository.On("RevokeUserSessions", mock.Anything, int32(1), "", now).Return(int64(2), nil)
+	repository.On("CreateSession", mock.Anything, mock.Anything).Return(nil)
+	repository.On("GetSession", mock.Anything, mock.Anything).Return(auth.Session{User: user}, nil)
+
+	fakeClock := new(mockClock)
+	fakeClock.On("Now").Return(now)
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository: repository,
+		Sessions:   repository,
+		Clock:      fakeClock,
+	})
+
+	ctx := context.Background()
+	session, err := service.ChangePassword(ctx, auth.ChangePasswordParams{
+		User:            user,
+		CurrentPassword: "old password",
+		NewPassword:     "violet-harbor-lantern-42",
+		SessionID:       "session1",
+	})
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, session.Token)
+	repository.AssertExpectations(t)
+
+	_, err = service.ChangePassword(ctx, auth.ChangePasswordParams{
+		User:            user,
+		CurrentPassword: "wrong password",
+		NewPassword:     "violet-harbor-lantern-42",
+	})
+
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// BreachedPasswords reports whether passwords appeared in data breaches.
+// Attackers try those first, no matter how strong they look.
+type BreachedPasswords interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
+
+//go:embed breached_passwords.txt
+var bundledBreachedPasswords string
+
+// commonPasswordRanks maps the bundled passwords to their position in the list, most common first.
+var commonPasswordRanks = sync.OnceValue(func() map[string]int {
+	ranks := map[string]int{}
+
+	for i, password := range strings.Fields(bundledBreachedPasswords) {
+		ranks[password] = i + 1
+	}
+
+	return ranks
+})
+
+// BundledBreachedPasswords knows the most common breached passwords. It is small
+// enough to ship with the binary, use [PwnedPasswordsDirectory] for a complete list.
+type BundledBreachedPasswords struct{}
+
+func (BundledBreachedPasswords) IsBreached(ctx context.Context, password string) (bool, error) {
+	_, ok := commonPasswordRanks()[strings.ToLower(password)]
+
+	return ok, nil
+}
+
+// PwnedPasswordsDirectory looks passwords up in a local copy of the Pwned Passwords range files.
+// Each file is named after the first 5 hex characters of the SHA-1 of the passwords,
+// e.g. `21BD1.txt`, and has a `SUFFIX:COUNT` line per password.
+type PwnedPasswordsDirectory struct {
+	dir string
+}
+
+func NewPwnedPasswordsDirectory(dir string) *PwnedPasswordsDirectory {
+	return &PwnedPasswordsDirectory{dir: dir}
+}
+
+func (p *PwnedPasswordsDirectory) IsBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(p.dir, prefix+".txt"))
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to open range file: %w", err)
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		lineSuffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+
+		// Padding lines have a count of 0.
+		if strings.EqualFold(lineSuffix, suffix) {
+			return count != "0", nil
+		}
+	}
+
+	err = scanner.Err()
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to read range file: %w", err)
+	}
+
+	return false, nil
+}
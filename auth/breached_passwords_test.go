@@ -0,0 +1,52 @@
+package auth_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundledBreachedPasswords(t *testing.T) {
+	ctx := context.Background()
+	breached := auth.BundledBreachedPasswords{}
+
+	ok, err := breached.IsBreached(ctx, "Password1")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = breached.IsBreached(ctx, "violet-harbor-lantern-42")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestPwnedPasswordsDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	// SHA-1 of `password` is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	rangeFile := "003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:10434004\r\n"
+	err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(rangeFile), 0o600)
+	assert.Nil(t, err)
+
+	// SHA-1 of `letmein` is B7A875FC1EA228B9061041B7CEC4BD3C52AB3CE3, padded with a count of 0.
+	err = os.WriteFile(filepath.Join(dir, "B7A87.txt"), []byte("5FC1EA228B9061041B7CEC4BD3C52AB3CE3:0\n"), 0o600)
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	breached := auth.NewPwnedPasswordsDirectory(dir)
+
+	ok, err := breached.IsBreached(ctx, "password")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = breached.IsBreached(ctx, "letmein")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = breached.IsBreached(ctx, "violet-harbor-lantern-42")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
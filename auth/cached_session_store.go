@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"expvar"
+	"sync"
+	"time"
+)
+
+var sessionCacheStats = expvar.NewMap("session_cache")
+
+// CachedSessionStore caches the sessions of another store for a short time, so
+// authenticating a request doesn't need a round trip to the database.
+// Revoking a session through the cache takes effect immediately. Revocations by
+// other instances take effect after the TTL, unless they are passed to
+// [CachedSessionStore.Invalidate].
+type CachedSessionStore struct {
+	store       SessionStore
+	size        int
+	ttl         time.Duration
+	fallbackTTL time.Duration
+	clock       Clock
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	byUser  map[int32]map[string]struct{}
+	// generation changes on every invalidation, so a session that was read
+	// while it was revoked isn't put into the cache.
+	generation uint64
+	synced     bool
+}
+
+type cachedSession struct {
+	session  Session
+	cachedAt time.Time
+}
+
+type NewCachedSessionStoreParams struct {
+	Store SessionStore
+	// Size is how many sessions are cached. Defaults to 10000.
+	Size int
+	// TTL is how long a session is cached. Defaults to 30 seconds.
+	TTL time.Duration
+	// FallbackTTL is how long a session is cached while revocations by other
+	// instances might be missed, see [CachedSessionStore.SetSynced].
+	// Defaults to the TTL.
+	FallbackTTL time.Duration
+	Clock       Clock
+}
+
+func NewCachedSessionStore(params NewCachedSessionStoreParams) *CachedSessionStore {
+	if params.Size == 0 {
+		params.Size = 10000
+	}
+
+	if params.TTL == 0 {
+		params.TTL = 30 * time.Second
+	}
+
+	if params.FallbackTTL == 0 {
+		params.FallbackTTL = params.TTL
+	}
+
+	return &CachedSessionStore{
+		store:       params.Store,
+		size:        params.Size,
+		ttl:         params.TTL,
+		fallbackTTL: params.FallbackTTL,
+		clock:       params.Clock,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		byUser:      make(map[int32]map[string]struct{}),
+	}
+}
+
+// GetSession implements SessionStore.
+func (c *CachedSessionStore) GetSession(ctx context.Context, sessionID string) (Session, error) {
+	now := c.clock.Now()
+
+	c.mu.Lock()
+
+	if element, ok := c.entries[sessionID]; ok {
+		entry := element.Value.(*cachedSession)
+
+		ttl := c.ttl
+
+		if !c.synced {
+			ttl = c.fallbackTTL
+		}
+
+		if now.Sub(entry.cachedAt) < ttl {
+			c.lru.MoveToFront(element)
+			c.mu.Unlock()
+			sessionCacheStats.Add("hits", 1)
+
+			return entry.session, nil
+		}
+
+		c.remove(element)
+	}
+
+	generation := c.generation
+	c.mu.Unlock()
+	sessionCacheStats.Add("misses", 1)
+
+	session, err := c.store.GetSession(ctx, sessionID)
+
+	if err != nil {
+		return session, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation == c.generation {
+		c.add(session, now)
+	}
+
+	return session, nil
+}
+
+// CreateSession implements SessionStore.
+func (c *CachedSessionStore) CreateSession(ctx context.Context, session Session) error {
+	return c.store.CreateSession(ctx, session)
+}
+
+// TouchSession implements SessionStore.
+func (c *CachedSessionStore) TouchSession(ctx context.Context, sessionID string, lastActiveAt time.Time, expiresAt time.Time) error {
+	err := c.store.TouchSession(ctx, sessionID, lastActiveAt, expiresAt)
+
+	if err != nil {
+		c.Invalidate(sessionID)
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[sessionID]; ok {
+		entry := element.Value.(*cachedSession)
+		entry.session.LastActiveAt = lastActiveAt
+		entry.session.ExpiresAt = expiresAt
+	}
+
+	return nil
+}
+
+// RevokeSession implements SessionStore.
+func (c *CachedSessionStore) RevokeSession(ctx context.Context, sessionID string, revokedAt time.Time) error {
+	defer c.Invalidate(sessionID)
+
+	return c.store.RevokeSession(ctx, sessionID, revokedAt)
+}
+
+// ListUserSessions implements SessionStore.
+func (c *CachedSessionStore) ListUserSessions(ctx context.Context, userID int32, now time.Time) ([]Session, error) {
+	return c.store.ListUserSessions(ctx, userID, now)
+}
+
+// RevokeUserSession implements SessionStore.
+func (c *CachedSessionStore) RevokeUserSession(ctx context.Context, userID int32, publicID string, revokedAt time.Time) (bool, error) {
+	defer c.InvalidateUser(userID)
+
+	return c.store.RevokeUserSession(ctx, userID, publicID, revokedAt)
+}
+
+// RevokeUserSessions implements SessionStore.
+func (c *CachedSessionStore) RevokeUserSessions(ctx context.Context, userID int32, exceptSessionID string, revokedAt time.Time) (int64, error) {
+	defer c.InvalidateUser(userID)
+
+	return c.store.RevokeUserSessions(ctx, userID, exceptSessionID, revokedAt)
+}
+
+// Invalidate removes a session from the cache.
+func (c *CachedSessionStore) Invalidate(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	if element, ok := c.entries[sessionID]; ok {
+		c.remove(element)
+		sessionCacheStats.Add("invalidations", 1)
+	}
+}
+
+// InvalidateUser removes all sessions of a user from the cache.
+func (c *CachedSessionStore) InvalidateUser(userID int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	for sessionID := range c.byUser[userID] {
+		c.remove(c.entries[sessionID])
+		sessionCacheStats.Add("invalidations", 1)
+	}
+}
+
+// SetSynced tells the cache whether it receives the revocations of other
+// instances. While it doesn't, sessions are cached for the fallback TTL.
+// When it is synced again, the sessions cached in between are dropped,
+// because revocations could have been missed.
+func (c *CachedSessionStore) SetSynced(synced bool) {
+	if synced {
+		c.InvalidateAll()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.synced = synced
+}
+
+// InvalidateAll empties the cache.
+func (c *CachedSessionStore) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.byUser = make(map[int32]map[string]struct{})
+}
+
+func (c *CachedSessionStore) add(session Session, now time.Time) {
+	if element, ok := c.entries[session.ID]; ok {
+		c.remove(element)
+	}
+
+	c.entries[session.ID] = c.lru.PushFront(&cachedSession{session: session, cachedAt: now})
+
+	if c.byUser[session.User.ID] == nil {
+		c.byUser[session.User.ID] = make(map[string]struct{})
+	}
+
+	c.byUser[session.User.ID][session.ID] = struct{}{}
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		sessionCacheStats.Add("evictions", 1)
+	}
+}
+
+func (c *CachedSessionStore) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cachedSession)
+	session := entry.session
+
+	delete(c.entries, session.ID)
+	delete(c.byUser[session.User.ID], session.ID)
+
+	if len(c.byUser[session.User.ID]) == 0 {
+		delete(c.byUser, session.User.ID)
+	}
+}
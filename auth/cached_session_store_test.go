@@ -0,0 +1,153 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+type stepClock struct {
+	now time.Time
+}
+
+// Now implements auth.Clock.
+func (c *stepClock) Now() time.Time {
+	return c.now
+}
+
+// countingSessionStore counts the reads that reach the underlying store.
+type countingSessionStore struct {
+	*auth.MemorySessionStore
+	reads int
+}
+
+func (c *countingSessionStore) GetSession(ctx context.Context, sessionID string) (auth.Session, error) {
+	c.reads++
+
+	return c.MemorySessionStore.GetSession(ctx, sessionID)
+}
+
+func newCachedStore(size int) (*auth.CachedSessionStore, *countingSessionStore, *stepClock) {
+	clock := &stepClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := &countingSessionStore{MemorySessionStore: auth.NewMemorySessionStore()}
+
+	cache := auth.NewCachedSessionStore(auth.NewCachedSessionStoreParams{
+		Store: store,
+		Size:  size,
+		TTL:   time.Minute,
+		Clock: clock,
+	})
+
+	return cache, store, clock
+}
+
+func TestCachedSessionStoreReadsThrough(t *testing.T) {
+	cache, store, clock := newCachedStore(10)
+	ctx := context.Background()
+
+	err := cache.CreateSession(ctx, auth.Session{ID: "session1", User: auth.User{ID: 1}})
+	assert.Nil(t, err)
+
+	for range 3 {
+		session, err := cache.GetSession(ctx, "session1")
+		assert.Nil(t, err)
+		assert.Equal(t, "session1", session.ID)
+	}
+
+	assert.Equal(t, 1, store.reads)
+
+	clock.now = clock.now.Add(time.Minute)
+
+	_, err = cache.GetSession(ctx, "session1")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, store.reads)
+}
+
+func TestCachedSessionStoreInvalidatesOnRevoke(t *testing.T) {
+	cache, _, clock := newCachedStore(10)
+	ctx := context.Background()
+
+	for _, id := range []string{"session1", "session2", "session3"} {
+		err := cache.CreateSession(ctx, auth.Session{ID: id, User: auth.User{ID: 1}})
+		assert.Nil(t, err)
+
+		_, err = cache.GetSession(ctx, id)
+		assert.Nil(t, err)
+	}
+
+	err := cache.RevokeSession(ctx, "session1", clock.now)
+	assert.Nil(t, err)
+
+	session, err := cache.GetSession(ctx, "session1")
+	assert.Nil(t, err)
+	assert.True(t, session.IsRevoked)
+
+	_, err = cache.RevokeUserSessions(ctx, 1, "session2", clock.now)
+	assert.Nil(t, err)
+
+	session, err = cache.GetSession(ctx, "session2")
+	assert.Nil(t, err)
+	assert.False(t, session.IsRevoked)
+
+	session, err = cache.GetSession(ctx, "session3")
+	assert.Nil(t, err)
+	assert.True(t, session.IsRevoked)
+}
+
+func TestCachedSessionStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, store, _ := newCachedStore(2)
+	ctx := context.Background()
+
+	for _, id := range []string{"session1", "session2", "session3"} {
+		err := cache.CreateSession(ctx, auth.Session{ID: id, User: auth.User{ID: 1}})
+		assert.Nil(t, err)
+	}
+
+	cache.GetSession(ctx, "session1")
+	cache.GetSession(ctx, "session2")
+	cache.GetSession(ctx, "session1")
+	cache.GetSession(ctx, "session3")
+	assert.Equal(t, 3, store.reads)
+
+	cache.GetSession(ctx, "session1")
+	assert.Equal(t, 3, store.reads)
+
+	cache.GetSession(ctx, "session2")
+	assert.Equal(t, 4, store.reads)
+}
+
+func TestCachedSessionStoreFallbackTTL(t *testing.T) {
+	clock := &stepClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := &countingSessionStore{MemorySessionStore: auth.NewMemorySessionStore()}
+
+	cache := auth.NewCachedSessionStore(auth.NewCachedSessionStoreParams{
+		Store:       store,
+		TTL:         time.Minute,
+		FallbackTTL: time.Second,
+		Clock:       clock,
+	})
+
+	ctx := context.Background()
+
+	err := cache.CreateSession(ctx, auth.Session{ID: "session1", User: auth.User{ID: 1}})
+	assert.Nil(t, err)
+
+	cache.GetSession(ctx, "session1")
+	clock.now = clock.now.Add(2 * time.Second)
+	cache.GetSession(ctx, "session1")
+	assert.Equal(t, 2, store.reads)
+
+	cache.SetSynced(true)
+
+	cache.GetSession(ctx, "session1")
+	clock.now = clock.now.Add(2 * time.Second)
+	cache.GetSession(ctx, "session1")
+	assert.Equal(t, 3, store.reads)
+
+	cache.InvalidateUser(1)
+	cache.GetSession(ctx, "session1")
+	assert.Equal(t, 4, store.reads)
+}
@@ -0,0 +1,42 @@
+package auth
+
+import "context"
+
+// Method is how a user authenticated.
+type Method string
+
+const (
+	MethodSession Method = "session"
+	MethodBearer  Method = "bearer"
+	MethodBasic   Method = "basic"
+)
+
+// Authentication is the result of authenticating a request.
+type Authentication struct {
+	User   User
+	Method Method
+	// SessionID is the hashed ID of the session, see [HashSessionToken].
+	// It is empty if the user didn't authenticate with a session.
+	SessionID string
+}
+
+type contextKey struct{}
+
+// WithAuthentication returns a context with the authenticated user.
+func WithAuthentication(ctx context.Context, authentication Authentication) context.Context {
+	return context.WithValue(ctx, contextKey{}, authentication)
+}
+
+// FromContext returns the authentication stored by [WithAuthentication].
+func FromContext(ctx context.Context) (Authentication, bool) {
+	authentication, ok := ctx.Value(contextKey{}).(Authentication)
+
+	return authentication, ok
+}
+
+// UserFromContext returns the authenticated user.
+func UserFromContext(ctx context.Context) (User, bool) {
+	authentication, ok := FromContext(ctx)
+
+	return authentication.User, ok
+}
@@ -0,0 +1,80 @@
+package auth
+
+import "strings"
+
+// Device is the client a session was created on.
+type Device struct {
+	UserAgent string
+	IP        string
+}
+
+var browsers = []struct {
+	token string
+	name  string
+}{
+	// Order matters, most browsers also claim to be Chrome or Safari.
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+}
+
+var operatingSystems = []struct {
+	token string
+	name  string
+}{
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Android", "Android"},
+	{"CrOS", "ChromeOS"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"Macintosh", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// Label describes the device in a way users recognize, e.g. `Firefox on Windows`.
+func (d Device) Label() string {
+	userAgent := d.UserAgent
+
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := ""
+
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	os := ""
+
+	for _, o := range operatingSystems {
+		if strings.Contains(userAgent, o.token) {
+			os = o.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	}
+
+	// Other clients, like curl/8.4.0, name themselves first.
+	product, _, _ := strings.Cut(userAgent, "/")
+	product, _, _ = strings.Cut(product, " ")
+
+	return product
+}
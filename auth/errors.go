@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrInvalidCredentials is returned for unknown usernames and wrong passwords
+	// alike, so clients can't find out which usernames exist.
+	ErrInvalidCredentials = errors.New("Invalid username or password")
+	ErrUsernameTaken      = errors.New("Username is taken")
+	ErrInvalidUsername    = errors.New("Username is empty or contains characters that aren't allowed")
+	ErrUserNotFound       = errors.New("User not found")
+	ErrSessionNotFound    = errors.New("Session not found")
+	ErrSessionExpired     = errors.New("Session expired")
+	ErrSessionRevoked     = errors.New("Session revoked")
+)
+
+// IsUnauthenticated reports whether err means that the credentials are wrong,
+// rather than that they couldn't be checked.
+func IsUnauthenticated(err error) bool {
+	return errors.Is(err, ErrInvalidCredentials) ||
+		errors.Is(err, ErrSessionNotFound) ||
+		errors.Is(err, ErrSessionExpired) ||
+		errors.Is(err, ErrSessionRevoked)
+}
+
+// FieldError says why the value of a request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem of the input, so clients can show them all at once.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+
+	return strings.Join(messages, ", ")
+}
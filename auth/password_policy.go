@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// bcryptMaxBytes is the longest password bcrypt hashes, it refuses longer ones.
+const bcryptMaxBytes = 72
+
+// Codes of [PasswordViolation].
+const (
+	PasswordTooShort         = "too_short"
+	PasswordTooLong          = "too_long"
+	PasswordTooWeak          = "too_weak"
+	PasswordContainsUsername = "contains_username"
+	PasswordBreached         = "breached"
+)
+
+// PasswordViolation is a rule of the [PasswordPolicy] that a password breaks.
+type PasswordViolation struct {
+	Code    string
+	Message string
+}
+
+// PasswordPolicy decides which passwords users can choose.
+type PasswordPolicy struct {
+	minLength   int
+	maxBytes    int
+	minStrength int
+	breached    BreachedPasswords
+}
+
+type NewPasswordPolicyParams struct {
+	// MinLength is the fewest characters a password has. Defaults to 8.
+	MinLength int
+	// MaxBytes is the longest a password is in bytes. It defaults to and can't exceed 72.
+	MaxBytes int
+	// MinStrength is the lowest [PasswordStrength] score that is accepted. Defaults to 2 if nil,
+	// 0 or less turns the check off.
+	MinStrength *int
+	// Breached defaults to [BundledBreachedPasswords].
+	Breached BreachedPasswords
+}
+
+func NewPasswordPolicy(params NewPasswordPolicyParams) *PasswordPolicy {
+	if params.MinLength == 0 {
+		params.MinLength = 8
+	}
+
+	if params.MaxBytes == 0 || params.MaxBytes > bcryptMaxBytes {
+		params.MaxBytes = bcryptMaxBytes
+	}
+
+	minStrength := 2
+
+	if params.MinStrength != nil {
+		minStrength = *params.MinStrength
+	}
+
+	if params.Breached == nil {
+		params.Breached = BundledBreachedPasswords{}
+	}
+
+	return &PasswordPolicy{
+		minLength:   params.MinLength,
+		maxBytes:    params.MaxBytes,
+		minStrength: minStrength,
+		breached:    params.Breached,
+	}
+}
+
+// Check returns every rule the password of the user breaks, so they can all be fixed at once.
+// The error is only set if the breached passwords couldn't be checked.
+func (p *PasswordPolicy) Check(ctx context.Context, username, password string) ([]PasswordViolation, error) {
+	var violations []PasswordViolation
+
+	if utf8.RuneCountInString(password) < p.minLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooShort,
+			Message: fmt.Sprintf("Password has to be at least %d characters long", p.minLength),
+		})
+	}
+
+	if len(password) > p.maxBytes {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooLong,
+			Message: fmt.Sprintf("Password can't be longer than %d bytes", p.maxBytes),
+		})
+
+		// It won't be hashed anyway, so there is no need to look at it closer.
+		return violations, nil
+	}
+
+	folded := FoldUsername(username)
+
+	if utf8.RuneCountInString(folded) >= 3 && strings.Contains(FoldUsername(password), folded) {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordContainsUsername,
+			Message: "Password can't contain the username",
+		})
+	}
+
+	if p.minStrength > 0 && EstimatePasswordStrength(password, username, folded).Score < p.minStrength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooWeak,
+			Message: "Password is too easy to guess, add words or characters that aren't common",
+		})
+	}
+
+	breached, err := p.breached.IsBreached(ctx, password)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to check breached passwords: %w", err)
+	}
+
+	if breached {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordBreached,
+			Message: "Password appeared in a data breach, choose another one",
+		})
+	}
+
+	return violations, nil
+}
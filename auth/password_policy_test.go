@@ -0,0 +1,86 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingBreachedPasswords struct{}
+
+func (failingBreachedPasswords) IsBreached(ctx context.Context, password string) (bool, error) {
+	return false, errors.New("disk on fire")
+}
+
+func violationCodes(violations []auth.PasswordViolation) []string {
+	codes := []string{}
+
+	for _, violation := range violations {
+		codes = append(codes, violation.Code)
+	}
+
+	return codes
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := auth.NewPasswordPolicy(auth.NewPasswordPolicyParams{})
+
+	tests := map[string][]string{
+		"violet-harbor-lantern-42":     {},
+		"":                             {auth.PasswordTooShort, auth.PasswordTooWeak},
+		"letmein":                      {auth.PasswordTooShort, auth.PasswordTooWeak, auth.PasswordBreached},
+		"password123":                  {auth.PasswordTooWeak, auth.PasswordBreached},
+		"violet-ALICE-lantern-42":      {auth.PasswordContainsUsername},
+		strings.Repeat("violet-", 11):  {auth.PasswordTooLong},
+		"correct horse battery staple": {},
+	}
+
+	for password, codes := range tests {
+		violations, err := policy.Check(context.Background(), "alice", password)
+
+		assert.Nil(t, err)
+		assert.Equal(t, codes, violationCodes(violations), password)
+	}
+}
+
+func TestPasswordPolicyMaxBytes(t *testing.T) {
+	policy := auth.NewPasswordPolicy(auth.NewPasswordPolicyParams{MaxBytes: 1000})
+
+	// 24 characters, but 72 bytes.
+	violations, err := policy.Check(context.Background(), "alice", strings.Repeat("密", 24))
+	assert.Nil(t, err)
+	assert.NotContains(t, violationCodes(violations), auth.PasswordTooLong)
+
+	violations, err = policy.Check(context.Background(), "alice", strings.Repeat("密", 25))
+	assert.Nil(t, err)
+	assert.Contains(t, violationCodes(violations), auth.PasswordTooLong)
+}
+
+func TestPasswordPolicyMinStrength(t *testing.T) {
+	tests := map[int][]string{
+		2:  {auth.PasswordTooWeak},
+		0:  {},
+		-1: {},
+	}
+
+	for minStrength, codes := range tests {
+		policy := auth.NewPasswordPolicy(auth.NewPasswordPolicyParams{MinStrength: &minStrength})
+
+		violations, err := policy.Check(context.Background(), "alice", "abcdefgh1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, codes, violationCodes(violations), minStrength)
+	}
+}
+
+func TestPasswordPolicyBreachedError(t *testing.T) {
+	policy := auth.NewPasswordPolicy(auth.NewPasswordPolicyParams{Breached: failingBreachedPasswords{}})
+
+	_, err := policy.Check(context.Background(), "alice", "violet-harbor-lantern-42")
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PasswordStrength estimates how hard a password is to guess, in the spirit of zxcvbn.
+type PasswordStrength struct {
+	// Guesses is the base 10 logarithm of how many guesses an attacker needs.
+	Guesses float64
+	// Score goes from 0, guessable in under a thousand tries, to 4, over ten billion.
+	Score int
+}
+
+// keyboardRows are walked by passwords like `qwerty` or `1qaz2wsx`.
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik,9ol.0p;/",
+	"789456123",
+}
+
+var leetSubstitutions = map[rune]rune{
+	'4': 'a',
+	'@': 'a',
+	'8': 'b',
+	'(': 'c',
+	'3': 'e',
+	'6': 'g',
+	'1': 'i',
+	'!': 'i',
+	'|': 'l',
+	'0': 'o',
+	'$': 's',
+	'5': 's',
+	'7': 't',
+	'+': 't',
+	'2': 'z',
+}
+
+// Words longer than this aren't looked up, no bundled password is that long.
+const maxDictionaryWordLength = 32
+
+type passwordMatch struct {
+	start   int
+	end     int
+	guesses float64
+}
+
+// EstimatePasswordStrength splits the password into the patterns that are cheapest to guess:
+// common passwords, the user inputs like the username, keyboard rows, sequences like `abc`,
+// repeats and years. What is left is guessed character by character.
+func EstimatePasswordStrength(password string, userInputs ...string) PasswordStrength {
+	runes := []rune(password)
+	lower := make([]rune, len(runes))
+
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	ranks := map[string]int{}
+
+	for _, input := range userInputs {
+		if input = strings.ToLower(input); input != "" {
+			ranks[input] = 1
+		}
+	}
+
+	var matches []passwordMatch
+
+	matches = append(matches, dictionaryMatches(runes, lower, ranks)...)
+	matches = append(matches, keyboardMatches(lower)...)
+	matches = append(matches, sequenceMatches(lower)...)
+	matches = append(matches, repeatMatches(lower)...)
+	matches = append(matches, yearMatches(lower)...)
+
+	// best[i] is the fewest guesses for the first i characters.
+	best := make([]float64, len(runes)+1)
+
+	for end := 1; end <= len(runes); end++ {
+		// Brute force, with 10 guesses per character.
+		best[end] = best[end-1] + 1
+
+		for _, match := range matches {
+			if match.end == end {
+				best[end] = math.Min(best[end], best[match.start]+match.guesses)
+			}
+		}
+	}
+
+	guesses := best[len(runes)]
+
+	return PasswordStrength{Guesses: guesses, Score: strengthScore(guesses)}
+}
+
+func strengthScore(guesses float64) int {
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func dictionaryMatches(runes, lower []rune, ranks map[string]int) []passwordMatch {
+	var matches []passwordMatch
+
+	common := commonPasswordRanks()
+
+	for start := range lower {
+		for end := start + 3; end <= len(lower) && end-start <= maxDictionaryWordLength; end++ {
+			word, substitutions := unleet(lower[start:end])
+			extra := uppercaseGuesses(runes[start:end]) + float64(substitutions)*math.Log10(2)
+
+			for _, candidate := range []string{word, reverse(word)} {
+				rank, ok := ranks[candidate]
+
+				if !ok {
+					rank, ok = common[candidate]
+				}
+
+				if !ok {
+					continue
+				}
+
+				guesses := math.Max(math.Log10(float64(rank)), 1) + extra
+
+				if candidate != word {
+					guesses += math.Log10(2)
+				}
+
+				matches = append(matches, passwordMatch{start: start, end: end, guesses: guesses})
+			}
+		}
+	}
+
+	return matches
+}
+
+func unleet(word []rune) (string, int) {
+	substitutions := 0
+	result := make([]rune, len(word))
+
+	for i, r := range word {
+		if substitution, ok := leetSubstitutions[r]; ok {
+			r = substitution
+			substitutions++
+		}
+
+		result[i] = r
+	}
+
+	return string(result), substitutions
+}
+
+func reverse(word string) string {
+	runes := []rune(word)
+
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+}
+
+// uppercaseGuesses is how many more guesses the capitalization of a word takes.
+// `Password` and `PASSWORD` are tried early, random capitals aren't.
+func uppercaseGuesses(word []rune) float64 {
+	upper, lower := 0, 0
+
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+
+	if upper == 0 {
+		return 0
+	}
+
+	if lower == 0 || upper == 1 && unicode.IsUpper(word[0]) {
+		return math.Log10(2)
+	}
+
+	variations := 0.0
+
+	for i := 1; i <= min(upper, lower); i++ {
+		variations += binomial(upper+lower, i)
+	}
+
+	return math.Log10(variations)
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+
+	return result
+}
+
+func keyboardMatches(lower []rune) []passwordMatch {
+	var matches []passwordMatch
+
+	for start := range lower {
+		end := start + 1
+
+		for end < len(lower) && isKeyboardRun(lower[start:end+1]) {
+			end++
+		}
+
+		if end-start >= 4 {
+			// About 47 keys to start from, times the length.
+			matches = append(matches, passwordMatch{start: start, end: end, guesses: math.Log10(47 * float64(end-start))})
+		}
+	}
+
+	return matches
+}
+
+func isKeyboardRun(run []rune) bool {
+	word := string(run)
+
+	for _, row := range keyboardRows {
+		if strings.Contains(row, word) || strings.Contains(row, reverse(word)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sequenceMatches(lower []rune) []passwordMatch {
+	var matches []passwordMatch
+
+	for start := 0; start < len(lower)-2; {
+		delta := lower[start+1] - lower[start]
+		end := start + 2
+
+		for end < len(lower) && lower[end]-lower[end-1] == delta {
+			end++
+		}
+
+		if (delta == 1 || delta == -1) && end-start >= 3 {
+			base := 26.0
+
+			if strings.ContainsRune("az019", lower[start]) {
+				base = 4
+			} else if unicode.IsDigit(lower[start]) {
+				base = 10
+			}
+
+			guesses := math.Log10(base * float64(end-start))
+
+			if delta < 0 {
+				guesses += math.Log10(2)
+			}
+
+			matches = append(matches, passwordMatch{start: start, end: end, guesses: guesses})
+		}
+
+		start = end - 1
+	}
+
+	return matches
+}
+
+func repeatMatches(lower []rune) []passwordMatch {
+	var matches []passwordMatch
+
+	for start := 0; start < len(lower); {
+		end := start + 1
+
+		for end < len(lower) && lower[end] == lower[start] {
+			end++
+		}
+
+		if end-start >= 3 {
+			matches = append(matches, passwordMatch{start: start, end: end, guesses: math.Log10(cardinality(lower[start]) * float64(end-start))})
+		}
+
+		start = end
+	}
+
+	return matches
+}
+
+func cardinality(r rune) float64 {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLetter(r):
+		return 26
+	default:
+		return 33
+	}
+}
+
+func yearMatches(lower []rune) []passwordMatch {
+	var matches []passwordMatch
+
+	now := time.Now().Year()
+
+	for start := 0; start+4 <= len(lower); start++ {
+		year := 0
+
+		for _, r := range lower[start : start+4] {
+			if r < '0' || r > '9' {
+				year = -1
+				break
+			}
+
+			year = year*10 + int(r-'0')
+		}
+
+		if year < 1900 || year > 2099 {
+			continue
+		}
+
+		distance := math.Max(math.Abs(float64(year-now)), 20)
+
+		matches = append(matches, passwordMatch{start: start, end: start + 4, guesses: math.Log10(distance)})
+	}
+
+	return matches
+}
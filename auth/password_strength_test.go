@@ -0,0 +1,34 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimatePasswordStrength(t *testing.T) {
+	tests := map[string]int{
+		"password":                     0,
+		"P@ssw0rd":                     0,
+		"qwertyuiop":                   0,
+		"abcdefgh":                     0,
+		"aaaaaaaaaa":                   0,
+		"drowssap":                     0,
+		"Summer2024":                   1,
+		"new password":                 1,
+		"violet-harbor-lantern-42":     4,
+		"correct horse battery staple": 4,
+	}
+
+	for password, score := range tests {
+		assert.Equal(t, score, auth.EstimatePasswordStrength(password).Score, password)
+	}
+}
+
+func TestEstimatePasswordStrengthUserInputs(t *testing.T) {
+	withoutInputs := auth.EstimatePasswordStrength("jdoe-hxz")
+	withInputs := auth.EstimatePasswordStrength("jdoe-hxz", "jdoe")
+
+	assert.Less(t, withInputs.Guesses, withoutInputs.Guesses)
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemorySessionStore keeps sessions in memory. It is meant for tests and
+// single instance setups, sessions are lost on restart.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]Session),
+	}
+}
+
+// GetSession implements SessionStore.
+func (m *MemorySessionStore) GetSession(ctx context.Context, sessionID string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[sessionID]
+
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+// CreateSession implements SessionStore.
+func (m *MemorySessionStore) CreateSession(ctx context.Context, session Session) error {
+	if session.PublicID == "" {
+		publicID, err := NewSessionID(16)
+
+		if err != nil {
+			return err
+		}
+
+		session.PublicID = publicID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[session.ID] = session
+
+	return nil
+}
+
+// TouchSession implements SessionStore.
+func (m *MemorySessionStore) TouchSession(ctx context.Context, sessionID string, lastActiveAt time.Time, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[sessionID]
+
+	if ok {
+		session.LastActiveAt = lastActiveAt
+		session.ExpiresAt = expiresAt
+		m.sessions[sessionID] = session
+	}
+
+	return nil
+}
+
+// RevokeSession implements SessionStore.
+func (m *MemorySessionStore) RevokeSession(ctx context.Context, sessionID string, revokedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revoke(sessionID, revokedAt)
+
+	return nil
+}
+
+// ListUserSessions implements SessionStore.
+func (m *MemorySessionStore) ListUserSessions(ctx context.Context, userID int32, now time.Time) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []Session
+
+	for _, session := range m.sessions {
+		if session.User.ID == userID && !session.IsRevoked && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	slices.SortFunc(sessions, func(a, b Session) int {
+		return cmp.Compare(b.LastActiveAt.UnixNano(), a.LastActiveAt.UnixNano())
+	})
+
+	return sessions, nil
+}
+
+// RevokeUserSession implements SessionStore.
+func (m *MemorySessionStore) RevokeUserSession(ctx context.Context, userID int32, publicID string, revokedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, session := range m.sessions {
+		if session.User.ID == userID && session.PublicID == publicID {
+			return m.revoke(id, revokedAt), nil
+		}
+	}
+
+	return false, nil
+}
+
+// RevokeUserSessions implements SessionStore.
+func (m *MemorySessionStore) RevokeUserSessions(ctx context.Context, userID int32, exceptSessionID string, revokedAt time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revoked int64
+
+	for id, session := range m.sessions {
+		if session.User.ID == userID && id != exceptSessionID && m.revoke(id, revokedAt) {
+			revoked++
+		}
+	}
+
+	return revoked, nil
+}
+
+func (m *MemorySessionStore) revoke(sessionID string, revokedAt time.Time) bool {
+	session, ok := m.sessions[sessionID]
+
+	if !ok || session.IsRevoked {
+		return false
+	}
+
+	session.IsRevoked = true
+	session.RevokedAt = revokedAt
+	m.sessions[sessionID] = session
+
+	return true
+}
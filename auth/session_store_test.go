@@ -0,0 +1,53 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	clock := &stepClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	service := auth.NewAuthService(auth.NewAuthServiceParams{
+		Sessions: auth.NewMemorySessionStore(),
+		Clock:    clock,
+	})
+
+	ctx := context.Background()
+	user := auth.User{ID: 1, Username: "user1"}
+
+	first, err := service.CreateSession(ctx, user, auth.Device{UserAgent: "curl/8.4.0"})
+	assert.Nil(t, err)
+
+	second, err := service.CreateSession(ctx, user, auth.Device{})
+	assert.Nil(t, err)
+
+	session, _, err := service.AuthenticateSession(ctx, first.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, user, session.User)
+	assert.Equal(t, "curl", session.DeviceLabel)
+
+	sessions, err := service.ListSessions(ctx, user.ID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 2)
+
+	err = service.RevokeUserSession(ctx, user.ID, second.PublicID)
+	assert.Nil(t, err)
+
+	err = service.RevokeUserSession(ctx, user.ID, second.PublicID)
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+
+	revoked, err := service.RevokeAllSessions(ctx, user.ID, "")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), revoked)
+
+	// Revocations take effect after the instant they were made.
+	clock.now = clock.now.Add(time.Second)
+
+	_, _, err = service.AuthenticateSession(ctx, first.Token)
+	assert.NotNil(t, err)
+}
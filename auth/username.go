@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var caseFolder = cases.Fold()
+
+// cjkScripts are written together, e.g. Japanese mixes Han, Hiragana and Katakana.
+var cjkScripts = map[string]bool{
+	"Han":      true,
+	"Hiragana": true,
+	"Katakana": true,
+	"Hangul":   true,
+	"Bopomofo": true,
+}
+
+// FoldUsername returns the form usernames are compared in, so `Alice`, `ALICE`
+// and `Ａｌｉｃｅ` are the same user. It applies NFKC and case folding.
+func FoldUsername(username string) string {
+	// Folding can produce characters NFKC would change again, so it is applied twice.
+	return norm.NFKC.String(caseFolder.String(norm.NFKC.String(username)))
+}
+
+// FoldUsernames returns the folded username of each user by ID, to normalize usernames stored
+// before they were folded. If several users fold to the same username, the one with the lowest
+// ID keeps it and the others get `-<ID>` appended until it is unique.
+func FoldUsernames(users []User) map[int32]string {
+	users = slices.SortedFunc(slices.Values(users), func(a, b User) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	folded := make(map[int32]string, len(users))
+	taken := make(map[string]bool, len(users))
+
+	for _, user := range users {
+		username := FoldUsername(user.Username)
+
+		for taken[username] {
+			username += "-" + strconv.Itoa(int(user.ID))
+		}
+
+		folded[user.ID] = username
+		taken[username] = true
+	}
+
+	return folded
+}
+
+// NormalizeUsername folds username and returns [ErrInvalidUsername] if it is empty,
+// contains invisible characters or mixes scripts that are easily confused,
+// like the Cyrillic `а` in `pаypal`.
+func NormalizeUsername(username string) (string, error) {
+	folded := FoldUsername(username)
+
+	if folded == "" {
+		return "", ErrInvalidUsername
+	}
+
+	scripts := map[string]bool{}
+
+	for _, r := range folded {
+		if unicode.IsSpace(r) || unicode.In(r, unicode.Cc, unicode.Cf, unicode.Co, unicode.Cs) || r == unicode.ReplacementChar {
+			return "", ErrInvalidUsername
+		}
+
+		if script := scriptOf(r); script != "" {
+			scripts[script] = true
+		}
+	}
+
+	if isConfusableMix(scripts) {
+		return "", ErrInvalidUsername
+	}
+
+	return folded, nil
+}
+
+// scriptOf returns the script of r, or "" for characters shared by all scripts like digits.
+func scriptOf(r rune) string {
+	if unicode.In(r, unicode.Common, unicode.Inherited) {
+		return ""
+	}
+
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// isConfusableMix allows Latin mixed with the CJK scripts, and any other script on its own.
+func isConfusableMix(scripts map[string]bool) bool {
+	others := 0
+	latinOrCJK := false
+
+	for script := range scripts {
+		if script == "Latin" || cjkScripts[script] {
+			latinOrCJK = true
+		} else {
+			others++
+		}
+	}
+
+	return others > 1 || others == 1 && latinOrCJK
+}
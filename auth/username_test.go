@@ -0,0 +1,63 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := map[string]string{
+		"alice":      "alice",
+		"Alice":      "alice",
+		"ＡＬＩＣＥ":      "alice",
+		"Straße":     "strasse",
+		"user_42":    "user_42",
+		"Ελένη":      "ελένη",
+		"やまだ太郎":      "やまだ太郎",
+		"tanaka-タロウ": "tanaka-タロウ",
+	}
+
+	for username, normalized := range tests {
+		result, err := auth.NormalizeUsername(username)
+
+		assert.Nil(t, err, username)
+		assert.Equal(t, normalized, result, username)
+	}
+}
+
+func TestNormalizeUsernameRejects(t *testing.T) {
+	usernames := []string{
+		"",
+		"ali ce",
+		"ali\u200bce",
+		"\u202eecila",
+		"p\u0430ypal",
+		"ελένη\u0430",
+	}
+
+	for _, username := range usernames {
+		_, err := auth.NormalizeUsername(username)
+
+		assert.ErrorIs(t, err, auth.ErrInvalidUsername, username)
+	}
+}
+
+func TestFoldUsernames(t *testing.T) {
+	users := []auth.User{
+		{ID: 4, Username: "strasse"},
+		{ID: 2, Username: "Straße"},
+		{ID: 1, Username: "alice"},
+		{ID: 3, Username: "ALICE"},
+		{ID: 5, Username: "alice-3"},
+	}
+
+	assert.Equal(t, map[int32]string{
+		1: "alice",
+		2: "strasse",
+		3: "alice-3",
+		4: "strasse-4",
+		5: "alice-3-5",
+	}, auth.FoldUsernames(users))
+}
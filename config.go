@@ -0,0 +1,368 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+)
+
+// Config holds the application configuration. It is loaded from environment variables.
+type Config struct {
+	DBConn string
+	Addr   string
+
+	// MaxBodySize is the largest request body in bytes.
+	MaxBodySize int64
+	// HandlerTimeout is the default deadline of handlers.
+	HandlerTimeout    time.Duration
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// TrustedProxies lists the networks of load balancers and proxies in front of the app.
+	TrustedProxies []netip.Prefix
+
+	// SessionIdleTimeout logs users out after they were inactive for this long.
+	SessionIdleTimeout time.Duration
+	// SessionLifetime is the longest a session lasts, no matter how active it is.
+	SessionLifetime time.Duration
+	// SessionRenewalWindow extends sessions that are used when they expire within it.
+	// It defaults to half of the idle timeout.
+	SessionRenewalWindow time.Duration
+
+	// SessionCacheSize and SessionCacheTTL configure the cache that saves a
+	// database round trip per authenticated request.
+	SessionCacheSize int64
+	SessionCacheTTL  time.Duration
+	// SessionCacheFallbackTTL is used instead of SessionCacheTTL while
+	// revocations of other instances can't be received.
+	SessionCacheFallbackTTL time.Duration
+
+	// SessionRetention is how long expired and revoked sessions are kept before they are deleted.
+	// SessionGCInterval is how often they are deleted, SessionGCBatchSize how many per statement.
+	SessionRetention   time.Duration
+	SessionGCInterval  time.Duration
+	SessionGCBatchSize int64
+
+	// PasswordMinLength and PasswordMinStrength configure the password policy.
+	// PasswordMinStrength is a score from 0 to 4, 0 turns the check off.
+	PasswordMinLength   int64
+	PasswordMinStrength int64
+	// PwnedPasswordsDir has the Pwned Passwords range files that passwords are checked against.
+	// Only the most common passwords are checked if it is empty.
+	PwnedPasswordsDir string
+
+	// SessionCookieSameSite has to be `none` if the API is called from another site.
+	SessionCookieSameSite http.SameSite
+
+	CORSAllowedOrigins []string
+	CORSMaxAge         time.Duration
+
+	// CSRFSecret signs CSRF tokens. It has to be shared by all instances.
+	CSRFSecret []byte
+
+	ContentSecurityPolicy string
+	CSPReportOnly         bool
+
+	// RateLimitStore is `memory` or `postgres`. Use `postgres` to share limits between instances.
+	RateLimitStore string
+	// IdempotencyStore is `memory` or `postgres`. Use `postgres` to share keys between instances.
+	IdempotencyStore string
+
+	// AdminToken has to be sent as a bearer token to use the admin routes.
+	// The admin routes are disabled if it is empty.
+	AdminToken string
+	// AdminAllowedIPs and AdminDeniedIPs restrict which clients can use the admin routes.
+	AdminAllowedIPs []netip.Prefix
+	AdminDeniedIPs  []netip.Prefix
+	// AdminIPAccessFile replaces AdminAllowedIPs and AdminDeniedIPs with a JSON file
+	// that is reloaded when it changes.
+	AdminIPAccessFile string
+	// MaintenanceFile and FeatureFlagsFile are JSON files that are reloaded on SIGHUP.
+	MaintenanceFile  string
+	FeatureFlagsFile string
+
+	// DebugCaptureSize is how many requests the debug capture keeps in memory.
+	DebugCaptureSize int64
+}
+
+func LoadConfig() (Config, error) {
+	config := Config{
+		DBConn:             os.Getenv("DB_CONN"),
+		Addr:               envOrDefault("ADDR", ":3000"),
+		CORSAllowedOrigins: envList("CORS_ALLOWED_ORIGINS"),
+		AdminToken:         os.Getenv("ADMIN_TOKEN"),
+		AdminIPAccessFile:  os.Getenv("ADMIN_IP_ACCESS_FILE"),
+		MaintenanceFile:    os.Getenv("MAINTENANCE_FILE"),
+		FeatureFlagsFile:   os.Getenv("FEATURE_FLAGS_FILE"),
+		PwnedPasswordsDir:  os.Getenv("PWNED_PASSWORDS_DIR"),
+	}
+
+	var err error
+
+	config.MaxBodySize, err = envInt("MAX_BODY_SIZE", 1<<20)
+
+	if err != nil {
+		return config, err
+	}
+
+	durations := []struct {
+		target       *time.Duration
+		key          string
+		defaultValue time.Duration
+	}{
+		{&config.HandlerTimeout, "HANDLER_TIMEOUT", 30 * time.Second},
+		{&config.ReadHeaderTimeout, "READ_HEADER_TIMEOUT", 5 * time.Second},
+		{&config.ReadTimeout, "READ_TIMEOUT", 30 * time.Second},
+		{&config.WriteTimeout, "WRITE_TIMEOUT", 60 * time.Second},
+		{&config.IdleTimeout, "IDLE_TIMEOUT", 120 * time.Second},
+		{&config.CORSMaxAge, "CORS_MAX_AGE", 10 * time.Minute},
+		{&config.SessionIdleTimeout, "SESSION_IDLE_TIMEOUT", 7 * 24 * time.Hour},
+		{&config.SessionLifetime, "SESSION_LIFETIME", 30 * 24 * time.Hour},
+		{&config.SessionRenewalWindow, "SESSION_RENEWAL_WINDOW", 0},
+		{&config.SessionCacheTTL, "SESSION_CACHE_TTL", 5 * time.Minute},
+		{&config.SessionCacheFallbackTTL, "SESSION_CACHE_FALLBACK_TTL", 10 * time.Second},
+		{&config.SessionRetention, "SESSION_RETENTION", 7 * 24 * time.Hour},
+		{&config.SessionGCInterval, "SESSION_GC_INTERVAL", 10 * time.Minute},
+	}
+
+	for _, duration := range durations {
+		*duration.target, err = envDuration(duration.key, duration.defaultValue)
+
+		if err != nil {
+			return config, err
+		}
+	}
+
+	// Every request would time out right away.
+	if config.HandlerTimeout <= 0 {
+		return config, fmt.Errorf("Invalid HANDLER_TIMEOUT: has to be positive")
+	}
+
+	if config.SessionGCInterval <= 0 {
+		return config, fmt.Errorf("Invalid SESSION_GC_INTERVAL: has to be positive")
+	}
+
+	config.TrustedProxies, err = middlewares.ParsePrefixes(envList("TRUSTED_PROXIES"))
+
+	if err != nil {
+		return config, err
+	}
+
+	config.AdminAllowedIPs, err = middlewares.ParsePrefixes(envList("ADMIN_ALLOWED_IPS"))
+
+	if err != nil {
+		return config, err
+	}
+
+	config.AdminDeniedIPs, err = middlewares.ParsePrefixes(envList("ADMIN_DENIED_IPS"))
+
+	if err != nil {
+		return config, err
+	}
+
+	config.SessionCookieSameSite, err = parseSameSite(envOrDefault("SESSION_COOKIE_SAME_SITE", "lax"))
+
+	if err != nil {
+		return config, err
+	}
+
+	config.CSRFSecret, err = envSecret("CSRF_SECRET")
+
+	if err != nil {
+		return config, err
+	}
+
+	config.ContentSecurityPolicy = envOrDefault("CONTENT_SECURITY_POLICY", "default-src 'none'")
+	config.CSPReportOnly, err = envBool("CSP_REPORT_ONLY", false)
+
+	if err != nil {
+		return config, err
+	}
+
+	config.RateLimitStore, err = envStore("RATE_LIMIT_STORE")
+
+	if err != nil {
+		return config, err
+	}
+
+	config.IdempotencyStore, err = envStore("IDEMPOTENCY_STORE")
+
+	if err != nil {
+		return config, err
+	}
+
+	config.SessionGCBatchSize, err = envInt("SESSION_GC_BATCH_SIZE", 1000)
+
+	if err != nil {
+		return config, err
+	}
+
+	// The janitor deletes batches until one isn't full, which never happens with 0.
+	if config.SessionGCBatchSize < 1 || config.SessionGCBatchSize > math.MaxInt32 {
+		return config, fmt.Errorf("Invalid SESSION_GC_BATCH_SIZE: has to be between 1 and %d", math.MaxInt32)
+	}
+
+	config.SessionCacheSize, err = envInt("SESSION_CACHE_SIZE", 10000)
+
+	if err != nil {
+		return config, err
+	}
+
+	if config.SessionCacheSize < 1 {
+		return config, fmt.Errorf("Invalid SESSION_CACHE_SIZE: has to be at least 1")
+	}
+
+	config.DebugCaptureSize, err = envInt("DEBUG_CAPTURE_SIZE", 100)
+
+	if err != nil {
+		return config, err
+	}
+
+	if config.DebugCaptureSize < 1 {
+		return config, fmt.Errorf("Invalid DEBUG_CAPTURE_SIZE: has to be at least 1")
+	}
+
+	config.PasswordMinLength, err = envInt("PASSWORD_MIN_LENGTH", 8)
+
+	if err != nil {
+		return config, err
+	}
+
+	config.PasswordMinStrength, err = envInt("PASSWORD_MIN_STRENGTH", 2)
+
+	if err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// envSecret reads a secret. If it is not set, a random one is generated,
+// which only works for a single instance and is lost on restart.
+func envSecret(key string) ([]byte, error) {
+	value := os.Getenv(key)
+
+	if value != "" {
+		return []byte(value), nil
+	}
+
+	log.Printf("%s is not set, using a random secret", key)
+
+	secret := make([]byte, 32)
+
+	_, err := rand.Read(secret)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate %s: %w", key, err)
+	}
+
+	return secret, nil
+}
+
+func envOrDefault(key string, defaultValue string) string {
+	value, ok := os.LookupEnv(key)
+
+	if !ok || value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+// envList reads a comma separated list.
+func envList(key string) []string {
+	values := make([]string, 0)
+
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		value = strings.TrimSpace(value)
+
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func envDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+
+	if err != nil {
+		return 0, fmt.Errorf("Invalid duration for %s: %w", key, err)
+	}
+
+	return duration, nil
+}
+
+// envStore reads the kind of a store, `memory` or `postgres`.
+func envStore(key string) (string, error) {
+	value := envOrDefault(key, "memory")
+
+	if value != "memory" && value != "postgres" {
+		return "", fmt.Errorf("Invalid %s: %s", key, value)
+	}
+
+	return value, nil
+}
+
+func envInt(key string, defaultValue int64) (int64, error) {
+	value := os.Getenv(key)
+
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil {
+		return 0, fmt.Errorf("Invalid number for %s: %w", key, err)
+	}
+
+	return n, nil
+}
+
+func envBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+
+	if err != nil {
+		return false, fmt.Errorf("Invalid boolean for %s: %w", key, err)
+	}
+
+	return b, nil
+}
+
+func parseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("Invalid SameSite value: %s", value)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigRejectsInvalidValues(t *testing.T) {
+	tests := map[string][]string{
+		"SESSION_GC_BATCH_SIZE": {"0", "-1", "2147483648"},
+		"SESSION_GC_INTERVAL":   {"0s", "-1m"},
+		"SESSION_CACHE_SIZE":    {"0", "-1"},
+		"DEBUG_CAPTURE_SIZE":    {"0", "-1"},
+		"HANDLER_TIMEOUT":       {"0s", "-1s"},
+	}
+
+	for key, values := range tests {
+		for _, value := range values {
+			t.Run(key+"="+value, func(t *testing.T) {
+				t.Setenv(key, value)
+
+				_, err := LoadConfig()
+
+				assert.ErrorContains(t, err, key)
+			})
+		}
+	}
+}
@@ -8,6 +8,23 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type IdempotencyKey struct {
+	Key         string
+	Fingerprint string
+	StatusCode  pgtype.Int4
+	Headers     []byte
+	Body        []byte
+	ExpiresAt   pgtype.Timestamptz
+}
+
+type RateLimit struct {
+	Key       string
+	Value     float64
+	Previous  float64
+	UpdatedAt pgtype.Timestamptz
+	ExpiresAt pgtype.Timestamptz
+}
+
 type Session struct {
 	ID           string
 	UserID       pgtype.Int4
@@ -15,6 +32,10 @@ type Session struct {
 	RevokedAt    pgtype.Timestamptz
 	ExpiresAt    pgtype.Timestamptz
 	LastActiveAt pgtype.Timestamptz
+	PublicID     string
+	UserAgent    string
+	Ip           string
+	DeviceLabel  string
 }
 
 type User struct {
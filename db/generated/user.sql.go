@@ -26,22 +26,65 @@ func (q *Queries) AddUser(ctx context.Context, arg AddUserParams) error {
 }
 
 const createSession = `-- name: CreateSession :exec
-INSERT INTO sessions(id, user_id, expires_at) VALUES ($1, $2, $3)
+INSERT INTO sessions(id, user_id, expires_at, last_active_at, user_agent, ip, device_label)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 `
 
 type CreateSessionParams struct {
-	ID        string
-	UserID    pgtype.Int4
-	ExpiresAt pgtype.Timestamptz
+	ID           string
+	UserID       pgtype.Int4
+	ExpiresAt    pgtype.Timestamptz
+	LastActiveAt pgtype.Timestamptz
+	UserAgent    string
+	Ip           string
+	DeviceLabel  string
 }
 
 func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
-	_, err := q.db.Exec(ctx, createSession, arg.ID, arg.UserID, arg.ExpiresAt)
+	_, err := q.db.Exec(ctx, createSession,
+		arg.ID,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.LastActiveAt,
+		arg.UserAgent,
+		arg.Ip,
+		arg.DeviceLabel,
+	)
+	return err
+}
+
+const deferUsernameConstraint = `-- name: DeferUsernameConstraint :exec
+SET CONSTRAINTS users_username_key DEFERRED
+`
+
+func (q *Queries) DeferUsernameConstraint(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, deferUsernameConstraint)
 	return err
 }
 
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE id IN (
+  SELECT id FROM sessions
+  WHERE expires_at < $1 OR revoked_at < $1
+  LIMIT $2
+)
+`
+
+type DeleteExpiredSessionsParams struct {
+	Before    pgtype.Timestamptz
+	BatchSize int32
+}
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, arg DeleteExpiredSessionsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredSessions, arg.Before, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getSession = `-- name: GetSession :one
-SELECT sessions.id, sessions.user_id, sessions.created_at, sessions.revoked_at, sessions.expires_at, sessions.last_active_at, users.username
+SELECT sessions.id, sessions.user_id, sessions.created_at, sessions.revoked_at, sessions.expires_at, sessions.last_active_at, sessions.public_id, sessions.user_agent, sessions.ip, sessions.device_label, users.username
 FROM sessions JOIN users on users.id = sessions.user_id
 WHERE sessions.id = $1
 `
@@ -53,6 +96,10 @@ type GetSessionRow struct {
 	RevokedAt    pgtype.Timestamptz
 	ExpiresAt    pgtype.Timestamptz
 	LastActiveAt pgtype.Timestamptz
+	PublicID     string
+	UserAgent    string
+	Ip           string
+	DeviceLabel  string
 	Username     string
 }
 
@@ -66,6 +113,10 @@ func (q *Queries) GetSession(ctx context.Context, id string) (GetSessionRow, err
 		&i.RevokedAt,
 		&i.ExpiresAt,
 		&i.LastActiveAt,
+		&i.PublicID,
+		&i.UserAgent,
+		&i.Ip,
+		&i.DeviceLabel,
 		&i.Username,
 	)
 	return i, err
@@ -86,3 +137,180 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 	err := row.Scan(&i.ID, &i.Username, &i.Password)
 	return i, err
 }
+
+const listUserSessions = `-- name: ListUserSessions :many
+SELECT id, user_id, created_at, revoked_at, expires_at, last_active_at, public_id, user_agent, ip, device_label FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+ORDER BY last_active_at DESC NULLS LAST
+`
+
+type ListUserSessionsParams struct {
+	UserID    pgtype.Int4
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) ListUserSessions(ctx context.Context, arg ListUserSessionsParams) ([]Session, error) {
+	rows, err := q.db.Query(ctx, listUserSessions, arg.UserID, arg.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.RevokedAt,
+			&i.ExpiresAt,
+			&i.LastActiveAt,
+			&i.PublicID,
+			&i.UserAgent,
+			&i.Ip,
+			&i.DeviceLabel,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsernamesForUpdate = `-- name: ListUsernamesForUpdate :many
+SELECT id, username FROM users ORDER BY id FOR UPDATE
+`
+
+type ListUsernamesForUpdateRow struct {
+	ID       int32
+	Username string
+}
+
+func (q *Queries) ListUsernamesForUpdate(ctx context.Context) ([]ListUsernamesForUpdateRow, error) {
+	rows, err := q.db.Query(ctx, listUsernamesForUpdate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsernamesForUpdateRow
+	for rows.Next() {
+		var i ListUsernamesForUpdateRow
+		if err := rows.Scan(&i.ID, &i.Username); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const notifySessionsRevoked = `-- name: NotifySessionsRevoked :exec
+SELECT pg_notify('sessions_revoked', $1::text)
+`
+
+func (q *Queries) NotifySessionsRevoked(ctx context.Context, payload string) error {
+	_, err := q.db.Exec(ctx, notifySessionsRevoked, payload)
+	return err
+}
+
+const revokeSession = `-- name: RevokeSession :execrows
+UPDATE sessions SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL
+`
+
+type RevokeSessionParams struct {
+	ID        string
+	RevokedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeSession, arg.ID, arg.RevokedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeUserSession = `-- name: RevokeUserSession :execrows
+UPDATE sessions SET revoked_at = $3 WHERE user_id = $1 AND public_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeUserSessionParams struct {
+	UserID    pgtype.Int4
+	PublicID  string
+	RevokedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUserSession, arg.UserID, arg.PublicID, arg.RevokedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeUserSessions = `-- name: RevokeUserSessions :execrows
+UPDATE sessions SET revoked_at = $1
+WHERE user_id = $2 AND id <> $3 AND revoked_at IS NULL
+`
+
+type RevokeUserSessionsParams struct {
+	RevokedAt pgtype.Timestamptz
+	UserID    pgtype.Int4
+	ExceptID  string
+}
+
+func (q *Queries) RevokeUserSessions(ctx context.Context, arg RevokeUserSessionsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUserSessions, arg.RevokedAt, arg.UserID, arg.ExceptID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const touchSession = `-- name: TouchSession :exec
+UPDATE sessions SET last_active_at = $2, expires_at = $3 WHERE id = $1
+`
+
+type TouchSessionParams struct {
+	ID           string
+	LastActiveAt pgtype.Timestamptz
+	ExpiresAt    pgtype.Timestamptz
+}
+
+func (q *Queries) TouchSession(ctx context.Context, arg TouchSessionParams) error {
+	_, err := q.db.Exec(ctx, touchSession, arg.ID, arg.LastActiveAt, arg.ExpiresAt)
+	return err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users SET password = $2 WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID       int32
+	Password string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.ID, arg.Password)
+	return err
+}
+
+const updateUsername = `-- name: UpdateUsername :exec
+UPDATE users SET username = $2 WHERE id = $1
+`
+
+type UpdateUsernameParams struct {
+	ID       int32
+	Username string
+}
+
+func (q *Queries) UpdateUsername(ctx context.Context, arg UpdateUsernameParams) error {
+	_, err := q.db.Exec(ctx, updateUsername, arg.ID, arg.Username)
+	return err
+}
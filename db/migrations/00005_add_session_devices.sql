@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sessions
+  ADD COLUMN public_id TEXT NOT NULL DEFAULT gen_random_uuid()::text,
+  ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+  ADD COLUMN ip TEXT NOT NULL DEFAULT '',
+  ADD COLUMN device_label TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX idx_sessions_public_id on sessions(public_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_sessions_public_id;
+
+ALTER TABLE sessions
+  DROP COLUMN IF EXISTS public_id,
+  DROP COLUMN IF EXISTS user_agent,
+  DROP COLUMN IF EXISTS ip,
+  DROP COLUMN IF EXISTS device_label;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sessions are looked up by the SHA-256 of their token, so existing sessions keep working.
+UPDATE sessions SET id = encode(sha256(convert_to(id, 'UTF8')), 'hex');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The tokens can't be recovered from their hashes, so everyone has to log in again.
+DELETE FROM sessions;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_sessions_expires_at on sessions(expires_at);
+CREATE INDEX idx_sessions_revoked_at on sessions(revoked_at) WHERE revoked_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+DROP INDEX IF EXISTS idx_sessions_revoked_at;
+-- +goose StatementEnd
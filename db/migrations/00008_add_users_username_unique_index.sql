@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Usernames are stored folded. Postgres can't fold exactly like the app, e.g. it keeps `ß`,
+-- so `go run . normalize-usernames` has to be run afterwards to finish the job.
+UPDATE users SET username = normalize(lower(normalize(username, NFKC)), NFKC);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- The oldest user keeps a username, the others get their ID appended until it is unique.
+DO $$
+BEGIN
+  LOOP
+    UPDATE users SET username = users.username || '-' || users.id
+    FROM users AS older
+    WHERE older.username = users.username AND older.id < users.id;
+
+    EXIT WHEN NOT FOUND;
+  END LOOP;
+END $$;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Deferrable, so normalize-usernames can swap usernames within a transaction.
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username) DEFERRABLE INITIALLY IMMEDIATE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The original usernames aren't kept, so they stay normalized.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- name: DeleteExpiredIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE key = $1 AND expires_at < CURRENT_TIMESTAMP;
+--
+-- name: InsertIdempotencyKey :execrows
+INSERT INTO idempotency_keys(key, fingerprint, expires_at) VALUES ($1, $2, $3)
+ON CONFLICT (key) DO NOTHING;
+--
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys WHERE key = $1;
+--
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys SET status_code = $2, headers = $3, body = $4, expires_at = $5 WHERE key = $1;
+--
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE key = $1;
+--
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE expires_at < $1;
@@ -0,0 +1,13 @@
+-- name: InsertRateLimitIfNotExists :exec
+INSERT INTO rate_limits(key, value, previous, updated_at, expires_at)
+VALUES ($1, 0, 0, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT (key) DO NOTHING;
+--
+-- name: GetRateLimitForUpdate :one
+SELECT * FROM rate_limits WHERE key = $1 FOR UPDATE;
+--
+-- name: UpdateRateLimit :exec
+UPDATE rate_limits SET value = $2, previous = $3, updated_at = $4, expires_at = $5 WHERE key = $1;
+--
+-- name: DeleteExpiredRateLimits :execrows
+DELETE FROM rate_limits WHERE expires_at < $1;
@@ -9,10 +9,51 @@ WHERE
 -- name: AddUser :exec
 INSERT INTO users(username, password) VALUES ($1,$2);
 --
+-- name: UpdateUserPassword :exec
+UPDATE users SET password = $2 WHERE id = $1;
+--
+-- name: ListUsernamesForUpdate :many
+SELECT id, username FROM users ORDER BY id FOR UPDATE;
+--
+-- name: UpdateUsername :exec
+UPDATE users SET username = $2 WHERE id = $1;
+--
+-- name: DeferUsernameConstraint :exec
+SET CONSTRAINTS users_username_key DEFERRED;
+--
 -- name: GetSession :one
 SELECT sessions.*, users.username
 FROM sessions JOIN users on users.id = sessions.user_id
 WHERE sessions.id = $1;
 --
 -- name: CreateSession :exec
-INSERT INTO sessions(id, user_id, expires_at) VALUES ($1, $2, $3);
+INSERT INTO sessions(id, user_id, expires_at, last_active_at, user_agent, ip, device_label)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+--
+-- name: RevokeSession :execrows
+UPDATE sessions SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL;
+--
+-- name: RevokeUserSessions :execrows
+UPDATE sessions SET revoked_at = sqlc.arg(revoked_at)
+WHERE user_id = sqlc.arg(user_id) AND id <> sqlc.arg(except_id) AND revoked_at IS NULL;
+--
+-- name: TouchSession :exec
+UPDATE sessions SET last_active_at = $2, expires_at = $3 WHERE id = $1;
+--
+-- name: ListUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+ORDER BY last_active_at DESC NULLS LAST;
+--
+-- name: RevokeUserSession :execrows
+UPDATE sessions SET revoked_at = $3 WHERE user_id = $1 AND public_id = $2 AND revoked_at IS NULL;
+--
+-- name: NotifySessionsRevoked :exec
+SELECT pg_notify('sessions_revoked', sqlc.arg(payload)::text);
+--
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE id IN (
+  SELECT id FROM sessions
+  WHERE expires_at < sqlc.arg(before) OR revoked_at < sqlc.arg(before)
+  LIMIT sqlc.arg(batch_size)
+);
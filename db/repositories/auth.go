@@ -2,11 +2,12 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/dpbrackin/ready-set-go/auth"
 	"github.com/dpbrackin/ready-set-go/db/generated"
-	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5"
 )
 
 type PGAuthRepository struct {
@@ -26,32 +27,29 @@ func (p *PGAuthRepository) AddUser(ctx context.Context, params auth.UserWithPass
 		Password: params.Password,
 	})
 
+	if isUniqueViolation(err) {
+		return auth.ErrUsernameTaken
+	}
+
 	return err
 }
 
-// GetSession implements auth.AuthRepository.
-func (p *PGAuthRepository) GetSession(ctx context.Context, sessionID string) (auth.Session, error) {
-	session, err := p.queries.GetSession(ctx, sessionID)
-
-	if err != nil {
-		return auth.Session{}, fmt.Errorf("Failed to get session: %w", err)
-	}
-
-	return auth.Session{
-		ID:           session.ID,
-		User:         auth.User{Username: session.Username, ID: session.UserID.Int32},
-		CreatedAt:    session.CreatedAt.Time,
-		RevokedAt:    session.RevokedAt.Time,
-		ExpiresAt:    session.ExpiresAt.Time,
-		LastActiveAt: session.LastActiveAt.Time,
-		IsRevoked:    session.RevokedAt.Valid,
-	}, nil
+// UpdateUserPassword implements auth.AuthRepository.
+func (p *PGAuthRepository) UpdateUserPassword(ctx context.Context, userID int32, password string) error {
+	return p.queries.UpdateUserPassword(ctx, generated.UpdateUserPasswordParams{
+		ID:       userID,
+		Password: password,
+	})
 }
 
 // GetUserByUsername implements auth.AuthRepository.
 func (p *PGAuthRepository) GetUserByUsername(ctx context.Context, username string) (auth.UserWithPassword, error) {
 	user, err := p.queries.GetUserByUsername(ctx, username)
 
+	if errors.Is(err, pgx.ErrNoRows) {
+		return auth.UserWithPassword{}, auth.ErrUserNotFound
+	}
+
 	if err != nil {
 		return auth.UserWithPassword{}, fmt.Errorf("Failed to get user: %w", err)
 	}
@@ -65,18 +63,64 @@ func (p *PGAuthRepository) GetUserByUsername(ctx context.Context, username strin
 	}, nil
 }
 
-func (p *PGAuthRepository) CreateSession(ctx context.Context, session auth.Session) error {
-	err := p.queries.CreateSession(ctx, generated.CreateSessionParams{
-		ID: session.ID,
-		UserID: pgtype.Int4{
-			Int32: int32(session.User.ID),
-			Valid: true,
-		},
-		ExpiresAt: pgtype.Timestamptz{
-			Time:  session.ExpiresAt,
-			Valid: true,
-		},
-	})
+// NormalizeUsernames folds the usernames stored before usernames were normalized,
+// see [auth.FoldUsernames]. It returns how many usernames changed.
+func NormalizeUsernames(ctx context.Context, db TxBeginner, queries *generated.Queries) (int64, error) {
+	tx, err := db.Begin(ctx)
 
-	return err
+	if err != nil {
+		return 0, fmt.Errorf("Failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	queries = queries.WithTx(tx)
+
+	// Users can swap usernames, so uniqueness is only checked on commit.
+	err = queries.DeferUsernameConstraint(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to defer username constraint: %w", err)
+	}
+
+	rows, err := queries.ListUsernamesForUpdate(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to list users: %w", err)
+	}
+
+	users := make([]auth.User, 0, len(rows))
+
+	for _, row := range rows {
+		users = append(users, auth.User{ID: row.ID, Username: row.Username})
+	}
+
+	folded := auth.FoldUsernames(users)
+
+	var changed int64
+
+	for _, user := range users {
+		if folded[user.ID] == user.Username {
+			continue
+		}
+
+		err = queries.UpdateUsername(ctx, generated.UpdateUsernameParams{
+			ID:       user.ID,
+			Username: folded[user.ID],
+		})
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to update username: %w", err)
+		}
+
+		changed++
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to commit usernames: %w", err)
+	}
+
+	return changed, nil
 }
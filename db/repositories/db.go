@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TxBeginner starts transactions, e.g. a [pgxpool.Pool].
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// isUniqueViolation reports whether err violates a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+
+	// 23505 is unique_violation.
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/db/generated"
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PGIdempotencyStore keeps idempotency keys in Postgres, so they are shared between instances.
+type PGIdempotencyStore struct {
+	queries *generated.Queries
+}
+
+func NewPGIdempotencyStore(queries *generated.Queries) *PGIdempotencyStore {
+	return &PGIdempotencyStore{
+		queries: queries,
+	}
+}
+
+// Begin implements middlewares.IdempotencyStore.
+func (p *PGIdempotencyStore) Begin(ctx context.Context, key string, fingerprint string, lockTimeout time.Duration) (middlewares.IdempotencyRecord, bool, error) {
+	err := p.queries.DeleteExpiredIdempotencyKey(ctx, key)
+
+	if err != nil {
+		return middlewares.IdempotencyRecord{}, false, fmt.Errorf("Failed to delete idempotency key: %w", err)
+	}
+
+	inserted, err := p.queries.InsertIdempotencyKey(ctx, generated.InsertIdempotencyKeyParams{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   pgtype.Timestamptz{Time: time.Now().Add(lockTimeout), Valid: true},
+	})
+
+	if err != nil {
+		return middlewares.IdempotencyRecord{}, false, fmt.Errorf("Failed to insert idempotency key: %w", err)
+	}
+
+	if inserted == 1 {
+		return middlewares.IdempotencyRecord{}, true, nil
+	}
+
+	idempotencyKey, err := p.queries.GetIdempotencyKey(ctx, key)
+
+	if err != nil {
+		return middlewares.IdempotencyRecord{}, false, fmt.Errorf("Failed to get idempotency key: %w", err)
+	}
+
+	record := middlewares.IdempotencyRecord{
+		Fingerprint: idempotencyKey.Fingerprint,
+	}
+
+	if idempotencyKey.StatusCode.Valid {
+		header := make(http.Header)
+
+		err = json.Unmarshal(idempotencyKey.Headers, &header)
+
+		if err != nil {
+			return middlewares.IdempotencyRecord{}, false, fmt.Errorf("Failed to decode headers: %w", err)
+		}
+
+		record.Response = &middlewares.IdempotentResponse{
+			StatusCode: int(idempotencyKey.StatusCode.Int32),
+			Header:     header,
+			Body:       idempotencyKey.Body,
+		}
+	}
+
+	return record, false, nil
+}
+
+// Complete implements middlewares.IdempotencyStore.
+func (p *PGIdempotencyStore) Complete(ctx context.Context, key string, response middlewares.IdempotentResponse, ttl time.Duration) error {
+	headers, err := json.Marshal(response.Header)
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode headers: %w", err)
+	}
+
+	err = p.queries.CompleteIdempotencyKey(ctx, generated.CompleteIdempotencyKeyParams{
+		Key:        key,
+		StatusCode: pgtype.Int4{Int32: int32(response.StatusCode), Valid: true},
+		Headers:    headers,
+		Body:       response.Body,
+		ExpiresAt:  pgtype.Timestamptz{Time: time.Now().Add(ttl), Valid: true},
+	})
+
+	if err != nil {
+		return fmt.Errorf("Failed to complete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// Release implements middlewares.IdempotencyStore.
+func (p *PGIdempotencyStore) Release(ctx context.Context, key string) error {
+	err := p.queries.DeleteIdempotencyKey(ctx, key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes idempotency keys that expired.
+func (p *PGIdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return p.queries.DeleteExpiredIdempotencyKeys(ctx, pgtype.Timestamptz{Time: time.Now(), Valid: true})
+}
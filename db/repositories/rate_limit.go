@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/db/generated"
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PGRateLimitStore keeps rate limits in Postgres, so they are shared between instances.
+type PGRateLimitStore struct {
+	db      TxBeginner
+	queries *generated.Queries
+}
+
+func NewPGRateLimitStore(db TxBeginner, queries *generated.Queries) *PGRateLimitStore {
+	return &PGRateLimitStore{
+		db:      db,
+		queries: queries,
+	}
+}
+
+// Update implements middlewares.RateLimitStore.
+func (p *PGRateLimitStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(state middlewares.RateLimitState, found bool) middlewares.RateLimitState) error {
+	tx, err := p.db.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	queries := p.queries.WithTx(tx)
+
+	err = queries.InsertRateLimitIfNotExists(ctx, key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to insert rate limit: %w", err)
+	}
+
+	rateLimit, err := queries.GetRateLimitForUpdate(ctx, key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to get rate limit: %w", err)
+	}
+
+	now := time.Now()
+
+	state := fn(middlewares.RateLimitState{
+		Value:     rateLimit.Value,
+		Previous:  rateLimit.Previous,
+		UpdatedAt: rateLimit.UpdatedAt.Time,
+	}, rateLimit.ExpiresAt.Time.After(now))
+
+	err = queries.UpdateRateLimit(ctx, generated.UpdateRateLimitParams{
+		Key:       key,
+		Value:     state.Value,
+		Previous:  state.Previous,
+		UpdatedAt: pgtype.Timestamptz{Time: state.UpdatedAt, Valid: true},
+		ExpiresAt: pgtype.Timestamptz{Time: now.Add(ttl), Valid: true},
+	})
+
+	if err != nil {
+		return fmt.Errorf("Failed to update rate limit: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteExpired removes rate limits that expired.
+func (p *PGRateLimitStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return p.queries.DeleteExpiredRateLimits(ctx, pgtype.Timestamptz{Time: time.Now(), Valid: true})
+}
@@ -0,0 +1,210 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/auth"
+	"github.com/dpbrackin/ready-set-go/db/generated"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type PGSessionStore struct {
+	queries *generated.Queries
+}
+
+func NewPGSessionStore(queries *generated.Queries) *PGSessionStore {
+	return &PGSessionStore{
+		queries: queries,
+	}
+}
+
+// GetSession implements auth.SessionStore.
+func (p *PGSessionStore) GetSession(ctx context.Context, sessionID string) (auth.Session, error) {
+	session, err := p.queries.GetSession(ctx, sessionID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return auth.Session{}, auth.ErrSessionNotFound
+	}
+
+	if err != nil {
+		return auth.Session{}, fmt.Errorf("Failed to get session: %w", err)
+	}
+
+	return auth.Session{
+		ID:           session.ID,
+		PublicID:     session.PublicID,
+		User:         auth.User{Username: session.Username, ID: session.UserID.Int32},
+		CreatedAt:    session.CreatedAt.Time,
+		RevokedAt:    session.RevokedAt.Time,
+		ExpiresAt:    session.ExpiresAt.Time,
+		LastActiveAt: session.LastActiveAt.Time,
+		IsRevoked:    session.RevokedAt.Valid,
+		UserAgent:    session.UserAgent,
+		IP:           session.Ip,
+		DeviceLabel:  session.DeviceLabel,
+	}, nil
+}
+
+// CreateSession implements auth.SessionStore.
+func (p *PGSessionStore) CreateSession(ctx context.Context, session auth.Session) error {
+	err := p.queries.CreateSession(ctx, generated.CreateSessionParams{
+		ID: session.ID,
+		UserID: pgtype.Int4{
+			Int32: int32(session.User.ID),
+			Valid: true,
+		},
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  session.ExpiresAt,
+			Valid: true,
+		},
+		LastActiveAt: pgtype.Timestamptz{
+			Time:  session.LastActiveAt,
+			Valid: true,
+		},
+		UserAgent:   session.UserAgent,
+		Ip:          session.IP,
+		DeviceLabel: session.DeviceLabel,
+	})
+
+	return err
+}
+
+// TouchSession implements auth.SessionStore.
+func (p *PGSessionStore) TouchSession(ctx context.Context, sessionID string, lastActiveAt time.Time, expiresAt time.Time) error {
+	return p.queries.TouchSession(ctx, generated.TouchSessionParams{
+		ID: sessionID,
+		LastActiveAt: pgtype.Timestamptz{
+			Time:  lastActiveAt,
+			Valid: true,
+		},
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  expiresAt,
+			Valid: true,
+		},
+	})
+}
+
+// RevokeSession implements auth.SessionStore.
+func (p *PGSessionStore) RevokeSession(ctx context.Context, sessionID string, revokedAt time.Time) error {
+	revoked, err := p.queries.RevokeSession(ctx, generated.RevokeSessionParams{
+		ID: sessionID,
+		RevokedAt: pgtype.Timestamptz{
+			Time:  revokedAt,
+			Valid: true,
+		},
+	})
+
+	if err != nil || revoked == 0 {
+		return err
+	}
+
+	p.notifyRevoked(ctx, SessionRevocation{SessionID: sessionID})
+
+	return nil
+}
+
+// RevokeUserSessions implements auth.SessionStore.
+func (p *PGSessionStore) RevokeUserSessions(ctx context.Context, userID int32, exceptSessionID string, revokedAt time.Time) (int64, error) {
+	revoked, err := p.queries.RevokeUserSessions(ctx, generated.RevokeUserSessionsParams{
+		RevokedAt: pgtype.Timestamptz{
+			Time:  revokedAt,
+			Valid: true,
+		},
+		UserID: pgtype.Int4{
+			Int32: userID,
+			Valid: true,
+		},
+		ExceptID: exceptSessionID,
+	})
+
+	if err != nil || revoked == 0 {
+		return revoked, err
+	}
+
+	p.notifyRevoked(ctx, SessionRevocation{UserID: userID})
+
+	return revoked, nil
+}
+
+// ListUserSessions implements auth.SessionStore.
+func (p *PGSessionStore) ListUserSessions(ctx context.Context, userID int32, now time.Time) ([]auth.Session, error) {
+	rows, err := p.queries.ListUserSessions(ctx, generated.ListUserSessionsParams{
+		UserID: pgtype.Int4{
+			Int32: userID,
+			Valid: true,
+		},
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  now,
+			Valid: true,
+		},
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list sessions: %w", err)
+	}
+
+	sessions := make([]auth.Session, 0, len(rows))
+
+	for _, session := range rows {
+		sessions = append(sessions, auth.Session{
+			ID:           session.ID,
+			PublicID:     session.PublicID,
+			User:         auth.User{ID: session.UserID.Int32},
+			CreatedAt:    session.CreatedAt.Time,
+			ExpiresAt:    session.ExpiresAt.Time,
+			LastActiveAt: session.LastActiveAt.Time,
+			UserAgent:    session.UserAgent,
+			IP:           session.Ip,
+			DeviceLabel:  session.DeviceLabel,
+		})
+	}
+
+	return sessions, nil
+}
+
+// RevokeUserSession implements auth.SessionStore.
+func (p *PGSessionStore) RevokeUserSession(ctx context.Context, userID int32, publicID string, revokedAt time.Time) (bool, error) {
+	revoked, err := p.queries.RevokeUserSession(ctx, generated.RevokeUserSessionParams{
+		UserID: pgtype.Int4{
+			Int32: userID,
+			Valid: true,
+		},
+		PublicID: publicID,
+		RevokedAt: pgtype.Timestamptz{
+			Time:  revokedAt,
+			Valid: true,
+		},
+	})
+
+	if err != nil || revoked == 0 {
+		return false, err
+	}
+
+	p.notifyRevoked(ctx, SessionRevocation{UserID: userID})
+
+	return true, nil
+}
+
+// notifyRevoked tells the other instances to drop the revoked sessions from their caches.
+// The sessions are already revoked, so a failure is only logged, the other
+// instances notice once their cached entries expire.
+func (p *PGSessionStore) notifyRevoked(ctx context.Context, revocation SessionRevocation) {
+	payload, err := json.Marshal(revocation)
+
+	if err != nil {
+		log.Printf("Failed to encode session revocation: %v", err)
+		return
+	}
+
+	err = p.queries.NotifySessionsRevoked(ctx, string(payload))
+
+	if err != nil {
+		log.Printf("Failed to notify session revocation: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/db/generated"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var sessionJanitorStats = expvar.NewMap("session_janitor")
+
+// sessionJanitorLock is the advisory lock that makes sure only one instance deletes sessions.
+const sessionJanitorLock int64 = 0x73657373696f6e73
+
+// SessionJanitor deletes sessions that expired or were revoked more than a retention window ago.
+type SessionJanitor struct {
+	pool      *pgxpool.Pool
+	retention time.Duration
+	batchSize int32
+}
+
+func NewSessionJanitor(pool *pgxpool.Pool, retention time.Duration, batchSize int32) *SessionJanitor {
+	return &SessionJanitor{
+		pool:      pool,
+		retention: retention,
+		batchSize: batchSize,
+	}
+}
+
+// DeleteExpired deletes the sessions in batches, so the table isn't locked for
+// long. It does nothing if another instance is already deleting them.
+func (j *SessionJanitor) DeleteExpired(ctx context.Context) (int64, error) {
+	conn, err := j.pool.Acquire(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to acquire connection: %w", err)
+	}
+
+	defer conn.Release()
+
+	queries := generated.New(conn)
+
+	locked, err := queries.TryAdvisoryLock(ctx, sessionJanitorLock)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to lock: %w", err)
+	}
+
+	if !locked {
+		sessionJanitorStats.Add("skipped", 1)
+		return 0, nil
+	}
+
+	// The lock belongs to the connection, so it has to be released before the connection is.
+	defer func() {
+		_, err := queries.AdvisoryUnlock(context.Background(), sessionJanitorLock)
+
+		if err != nil {
+			log.Printf("Failed to unlock the session janitor: %v", err)
+		}
+	}()
+
+	sessionJanitorStats.Add("runs", 1)
+
+	before := pgtype.Timestamptz{Time: time.Now().Add(-j.retention), Valid: true}
+
+	var total int64
+
+	for {
+		deleted, err := queries.DeleteExpiredSessions(ctx, generated.DeleteExpiredSessionsParams{
+			Before:    before,
+			BatchSize: j.batchSize,
+		})
+
+		total += deleted
+		sessionJanitorStats.Add("deleted", deleted)
+
+		if err != nil {
+			return total, fmt.Errorf("Failed to delete sessions: %w", err)
+		}
+
+		if deleted < int64(j.batchSize) {
+			return total, nil
+		}
+	}
+}
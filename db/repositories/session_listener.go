@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// sessionsRevokedChannel is the channel revocations are published on with NOTIFY.
+const sessionsRevokedChannel = "sessions_revoked"
+
+// SessionRevocation is published when sessions are revoked. Either the
+// session or all sessions of the user were revoked.
+type SessionRevocation struct {
+	SessionID string `json:"session_id,omitempty"`
+	UserID    int32  `json:"user_id,omitempty"`
+}
+
+// SessionInvalidator drops revoked sessions from a cache, e.g. an [auth.CachedSessionStore].
+type SessionInvalidator interface {
+	Invalidate(sessionID string)
+	InvalidateUser(userID int32)
+	// SetSynced is false while revocations might be missed.
+	SetSynced(synced bool)
+}
+
+// ListenSessionRevocations passes the revocations of all instances to the
+// invalidator until ctx is done. It reconnects with a backoff if the
+// connection is lost.
+func ListenSessionRevocations(ctx context.Context, pool *pgxpool.Pool, invalidator SessionInvalidator) {
+	backoff := time.Second
+
+	for {
+		err := listenSessionRevocations(ctx, pool, invalidator, func() {
+			backoff = time.Second
+		})
+
+		invalidator.SetSynced(false)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("Session revocation listener failed, retrying in %v: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func listenSessionRevocations(ctx context.Context, pool *pgxpool.Pool, invalidator SessionInvalidator, onListening func()) error {
+	pooled, err := pool.Acquire(ctx)
+
+	if err != nil {
+		return fmt.Errorf("Failed to acquire connection: %w", err)
+	}
+
+	// The connection is taken out of the pool, it is busy waiting for notifications.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+sessionsRevokedChannel)
+
+	if err != nil {
+		return fmt.Errorf("Failed to listen: %w", err)
+	}
+
+	onListening()
+	invalidator.SetSynced(true)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+
+		if err != nil {
+			return fmt.Errorf("Failed to wait for notification: %w", err)
+		}
+
+		var revocation SessionRevocation
+
+		err = json.Unmarshal([]byte(notification.Payload), &revocation)
+
+		if err != nil {
+			log.Printf("Invalid session revocation %q: %v", notification.Payload, err)
+			continue
+		}
+
+		if revocation.SessionID != "" {
+			invalidator.Invalidate(revocation.SessionID)
+		}
+
+		if revocation.UserID != 0 {
+			invalidator.InvalidateUser(revocation.UserID)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package features implements feature flags that are evaluated per user.
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// Flag decides who a feature is enabled for.
+type Flag struct {
+	// Enabled turns the feature on for everyone.
+	Enabled bool `json:"enabled"`
+	// Users lists the subjects the feature is enabled for, e.g. user IDs.
+	Users []string `json:"users"`
+	// Percentage of subjects the feature is enabled for. A subject always gets the same result.
+	Percentage int `json:"percentage"`
+}
+
+// Flags holds the feature flags of the app, which can be changed at runtime.
+// Unknown flags are disabled.
+type Flags struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+func NewFlags(flags map[string]Flag) *Flags {
+	if flags == nil {
+		flags = make(map[string]Flag)
+	}
+
+	return &Flags{
+		flags: flags,
+	}
+}
+
+// Enabled reports whether the feature is enabled for subject.
+// The subject is empty for anonymous users.
+func (f *Flags) Enabled(name string, subject string) bool {
+	f.mu.RLock()
+	flag, ok := f.flags[name]
+	f.mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	if flag.Enabled {
+		return true
+	}
+
+	if subject == "" {
+		return false
+	}
+
+	if slices.Contains(flag.Users, subject) {
+		return true
+	}
+
+	return flag.Percentage > 0 && bucket(name, subject) < flag.Percentage
+}
+
+// bucket maps a subject to a number in [0, 100), different for every flag.
+func bucket(name string, subject string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(subject))
+
+	return int(h.Sum32() % 100)
+}
+
+func (f *Flags) Set(name string, flag Flag) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.flags[name] = flag
+}
+
+func (f *Flags) All() map[string]Flag {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	flags := make(map[string]Flag, len(f.flags))
+
+	for name, flag := range f.flags {
+		flags[name] = flag
+	}
+
+	return flags
+}
+
+// LoadFile replaces all flags with the ones in a JSON file that maps names to flags.
+// A missing file disables all flags.
+func (f *Flags) LoadFile(path string) error {
+	flags := make(map[string]Flag)
+	data, err := os.ReadFile(path)
+
+	switch {
+	case os.IsNotExist(err):
+		// Nothing to parse, the flags are cleared.
+	case err != nil:
+		return fmt.Errorf("Failed to read feature flags: %w", err)
+	default:
+		err = json.Unmarshal(data, &flags)
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse feature flags: %w", err)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.flags = flags
+
+	return nil
+}
+
+// Require responds with a 404 if the feature is disabled for the subject of the request.
+func Require(flags *Flags, name string, subject func(r *http.Request) string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !flags.Enabled(name, subject(r)) {
+				http.NotFound(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ServeHTTP returns all flags on GET. On PUT it sets the flag named by the `name` path value.
+func (f *Flags) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		var flag Flag
+
+		err := json.NewDecoder(r.Body).Decode(&flag)
+
+		if err != nil || r.PathValue("name") == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid feature flag"))
+			return
+		}
+
+		f.Set(r.PathValue("name"), flag)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(f.All())
+}
@@ -0,0 +1,89 @@
+package features_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/features"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnabled(t *testing.T) {
+	flags := features.NewFlags(map[string]features.Flag{
+		"everyone": {Enabled: true},
+		"beta":     {Users: []string{"1"}},
+	})
+
+	assert.True(t, flags.Enabled("everyone", ""))
+	assert.True(t, flags.Enabled("beta", "1"))
+	assert.False(t, flags.Enabled("beta", "2"))
+	assert.False(t, flags.Enabled("beta", ""))
+	assert.False(t, flags.Enabled("unknown", "1"))
+}
+
+func TestPercentage(t *testing.T) {
+	flags := features.NewFlags(map[string]features.Flag{
+		"rollout": {Percentage: 30},
+	})
+
+	enabled := 0
+
+	for i := range 1000 {
+		subject := fmt.Sprint(i)
+
+		if flags.Enabled("rollout", subject) {
+			enabled++
+		}
+
+		assert.Equal(t, flags.Enabled("rollout", subject), flags.Enabled("rollout", subject))
+	}
+
+	assert.InDelta(t, 300, enabled, 60)
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "features.json")
+	flags := features.NewFlags(nil)
+
+	err := os.WriteFile(path, []byte(`{"beta": {"enabled": true}}`), 0o600)
+	assert.Nil(t, err)
+
+	err = flags.LoadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, flags.Enabled("beta", "1"))
+
+	err = os.Remove(path)
+	assert.Nil(t, err)
+
+	err = flags.LoadFile(path)
+	assert.Nil(t, err)
+	assert.False(t, flags.Enabled("beta", "1"))
+
+	err = os.WriteFile(path, []byte(`{`), 0o600)
+	assert.Nil(t, err)
+
+	err = flags.LoadFile(path)
+	assert.NotNil(t, err)
+}
+
+func TestRequire(t *testing.T) {
+	flags := features.NewFlags(map[string]features.Flag{
+		"beta": {Users: []string{"1"}},
+	})
+
+	handler := features.Require(flags, "beta", func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-User", "2")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -3,16 +3,23 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/dpbrackin/ready-set-go/auth"
 	"github.com/dpbrackin/ready-set-go/db/generated"
 	"github.com/dpbrackin/ready-set-go/db/repositories"
+	"github.com/dpbrackin/ready-set-go/features"
+	"github.com/dpbrackin/ready-set-go/middlewares"
 	"github.com/dpbrackin/ready-set-go/router"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type RealClock struct{}
@@ -22,42 +29,249 @@ func (r *RealClock) Now() time.Time {
 }
 
 func main() {
+	config, err := LoadConfig()
+
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
 	ctx := context.Background()
-	conn, err := pgx.Connect(ctx, os.Getenv("DB_CONN"))
+	pool, err := pgxpool.New(ctx, config.DBConn)
 
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
 
-	q := generated.New(conn)
+	defer pool.Close()
+
+	q := generated.New(pool)
+
+	sessionJanitor := repositories.NewSessionJanitor(pool, config.SessionRetention, int32(config.SessionGCBatchSize))
+
+	if len(os.Args) > 1 {
+		runCommand(ctx, os.Args[1], pool, q, sessionJanitor)
+		return
+	}
+
+	go deleteExpiredPeriodically(ctx, "sessions", sessionJanitor, config.SessionGCInterval)
+
+	var rateLimitStore middlewares.RateLimitStore = middlewares.NewMemoryRateLimitStore()
+
+	if config.RateLimitStore == "postgres" {
+		pgRateLimitStore := repositories.NewPGRateLimitStore(pool, q)
+		go deleteExpiredPeriodically(ctx, "rate limits", pgRateLimitStore, 10*time.Minute)
+		rateLimitStore = pgRateLimitStore
+	}
+
+	var idempotencyStore middlewares.IdempotencyStore = middlewares.NewMemoryIdempotencyStore()
+
+	if config.IdempotencyStore == "postgres" {
+		pgIdempotencyStore := repositories.NewPGIdempotencyStore(q)
+		go deleteExpiredPeriodically(ctx, "idempotency keys", pgIdempotencyStore, 10*time.Minute)
+		idempotencyStore = pgIdempotencyStore
+	}
+
+	maintenance := middlewares.NewMaintenance()
+	featureFlags := features.NewFlags(nil)
+
+	err = loadRuntimeConfig(config, maintenance, featureFlags)
+
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	go reloadRuntimeConfigOnSignal(config, maintenance, featureFlags)
+
+	adminIPAccess := middlewares.NewIPAccessList(config.AdminAllowedIPs, config.AdminDeniedIPs)
+
+	if config.AdminIPAccessFile != "" {
+		err = adminIPAccess.LoadFile(config.AdminIPAccessFile)
+
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+
+		go adminIPAccess.WatchFile(ctx, config.AdminIPAccessFile, 10*time.Second)
+	}
+
+	sessionCache := auth.NewCachedSessionStore(auth.NewCachedSessionStoreParams{
+		Store:       repositories.NewPGSessionStore(q),
+		Size:        int(config.SessionCacheSize),
+		TTL:         config.SessionCacheTTL,
+		FallbackTTL: config.SessionCacheFallbackTTL,
+		Clock:       &RealClock{},
+	})
+
+	// Sessions revoked by other instances are dropped from the cache.
+	go repositories.ListenSessionRevocations(ctx, pool, sessionCache)
+
+	minStrength := int(config.PasswordMinStrength)
+
+	passwordPolicyParams := auth.NewPasswordPolicyParams{
+		MinLength:   int(config.PasswordMinLength),
+		MinStrength: &minStrength,
+	}
+
+	if config.PwnedPasswordsDir != "" {
+		passwordPolicyParams.Breached = auth.NewPwnedPasswordsDirectory(config.PwnedPasswordsDir)
+	}
 
 	authService := auth.NewAuthService(auth.NewAuthServiceParams{
-		Repository: repositories.NewPGAuthRepository(q),
-		Clock:      &RealClock{},
+		Repository:           repositories.NewPGAuthRepository(q),
+		Sessions:             sessionCache,
+		Clock:                &RealClock{},
+		SessionIdleTimeout:   config.SessionIdleTimeout,
+		SessionLifetime:      config.SessionLifetime,
+		SessionRenewalWindow: config.SessionRenewalWindow,
+		PasswordPolicy:       auth.NewPasswordPolicy(passwordPolicyParams),
 	})
 
 	authHandlers := &AuthHandlers{
-		Srv: authService,
+		Srv:            authService,
+		CookieSameSite: config.SessionCookieSameSite,
+		CSRFSecret:     config.CSRFSecret,
 	}
 
+	securityHeaders := middlewares.DefaultSecurityHeadersOptions()
+	securityHeaders.ContentSecurityPolicy = config.ContentSecurityPolicy
+	securityHeaders.CSPReportOnly = config.CSPReportOnly
+	securityHeaders.CSPReportURI = "/csp-report"
+
+	// Faults can only be injected in builds with the `faultinject` tag.
+	faultInjector := middlewares.NewFaultInjector()
+
 	root := router.NewRootRouter()
+	root.Use(middlewares.RealIP(middlewares.RealIPOptions{
+		TrustedProxies: config.TrustedProxies,
+	}))
 	root.Use(LoggingMiddleware)
+	root.Use(faultInjector.Middleware())
+	root.Use(middlewares.MaxBodySize(config.MaxBodySize))
+	root.Use(middlewares.Timeout(middlewares.TimeoutOptions{Timeout: config.HandlerTimeout}))
+	root.Use(middlewares.SecurityHeaders(securityHeaders))
+	root.Use(middlewares.Compress(middlewares.CompressOptions{}))
+	root.Use(middlewares.ETag(middlewares.ETagOptions{}))
+	root.Use(middlewares.CORS(middlewares.CORSOptions{
+		AllowedOrigins:   config.CORSAllowedOrigins,
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "X-CSRF-Token", "Idempotency-Key"},
+		ExposedHeaders:   []string{"WWW-Authenticate", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Idempotent-Replayed"},
+		AllowCredentials: true,
+		MaxAge:           config.CORSMaxAge,
+	}))
+
+	// Reports are logged, so they are kept small and few.
+	root.RouteFunc("POST /csp-report", middlewares.CSPReportHandler, middlewares.RateLimit(middlewares.RateLimitOptions{
+		Name:    "csp-report",
+		Limiter: &middlewares.TokenBucket{Limit: 30, Period: time.Minute, Burst: 10, Store: rateLimitStore},
+		Key:     middlewares.KeyByIP,
+	}), middlewares.MaxBodySize(8<<10))
+	root.RouteFunc("GET /health", healthHandler(pool))
+
+	debugCapture := middlewares.NewDebugCapture(middlewares.DebugCaptureOptions{
+		Capacity: int(config.DebugCaptureSize),
+		Subject:  idempotencyScope,
+	})
+
+	adminGroup := root.Group("/admin")
+	adminGroup.Use(middlewares.IPFilter("admin", adminIPAccess))
+	adminGroup.Use(AdminMiddleware(config.AdminToken))
+	adminGroup.RouteFunc("GET /metrics", expvar.Handler().ServeHTTP)
+	adminGroup.RouteFunc("GET /maintenance", maintenance.ServeHTTP)
+	adminGroup.RouteFunc("PUT /maintenance", maintenance.ServeHTTP)
+	adminGroup.RouteFunc("GET /features", featureFlags.ServeHTTP)
+	adminGroup.RouteFunc("PUT /features/{name}", featureFlags.ServeHTTP)
+	adminGroup.RouteFunc("GET /debug/captures", debugCapture.ServeCaptures)
+	adminGroup.RouteFunc("GET /debug/captures.har", debugCapture.ServeHAR)
+	adminGroup.RouteFunc("GET /debug/rules", debugCapture.ServeRules)
+	adminGroup.RouteFunc("PUT /debug/rules", debugCapture.ServeRules)
+	adminGroup.RouteFunc("POST /debug/tokens", debugCapture.ServeToken)
+	adminGroup.RouteFunc("GET /faults", faultInjector.ServeHTTP)
+	adminGroup.RouteFunc("PUT /faults", faultInjector.ServeHTTP)
+
+	// Logins and Basic credentials share these limits, so passwords can't be guessed faster with Basic.
+	ipLimiter := &middlewares.TokenBucket{Limit: 30, Period: time.Minute, Burst: 10, Store: rateLimitStore}
+	usernameLimiter := &middlewares.SlidingWindow{Limit: 10, Window: 15 * time.Minute, Store: rateLimitStore}
 
 	unauthenticatedGroup := root.Group("")
-	unauthenticatedGroup.RouteFunc("POST /login", authHandlers.Login)
-	unauthenticatedGroup.RouteFunc("POST /register", authHandlers.Register)
+	unauthenticatedGroup.Use(maintenance.Middleware())
+	unauthenticatedGroup.Use(debugCapture.Middleware())
+	// Only the origin is checked here, so a stale session cookie doesn't block logging in.
+	unauthenticatedGroup.Use(middlewares.CSRF(middlewares.CSRFOptions{
+		TrustedOrigins: config.CORSAllowedOrigins,
+	}))
+	unauthenticatedGroup.Use(middlewares.RateLimit(middlewares.RateLimitOptions{
+		Name:    "ip",
+		Limiter: ipLimiter,
+		Key:     middlewares.KeyByIP,
+	}))
+	unauthenticatedGroup.Use(middlewares.RateLimit(middlewares.RateLimitOptions{
+		Name:    "username",
+		Limiter: usernameLimiter,
+		Key:     keyByFoldedUsername(middlewares.KeyByJSONField("username")),
+	}))
+	credentialsBodySize := middlewares.MaxBodySize(4 << 10)
+	unauthenticatedGroup.RouteFunc("POST /login", authHandlers.Login, credentialsBodySize)
+	unauthenticatedGroup.RouteFunc("POST /register", authHandlers.Register, credentialsBodySize, middlewares.Idempotency(middlewares.IdempotencyOptions{
+		Store: idempotencyStore,
+		Scope: idempotencyScope,
+	}))
 
 	authenticatedGroup := root.Group("")
-	authenticatedGroup.Use(AuthMiddleware(authService))
-	authenticatedGroup.RouteFunc("GET /logout", authHandlers.Logout)
-	authenticatedGroup.RouteFunc("GET /whoami", authHandlers.WhoAmI)
-
-	addr := ":3000"
-
-	log.Printf("Listening on %s", addr)
+	authenticatedGroup.Use(maintenance.Middleware())
+	authenticatedGroup.Use(middlewares.CSRF(middlewares.CSRFOptions{
+		Secret:         config.CSRFSecret,
+		SessionCookie:  "sessionID",
+		TrustedOrigins: config.CORSAllowedOrigins,
+	}))
+	// Failed Basic authentications count like failed logins, successful ones are given back.
+	authenticatedGroup.Use(middlewares.RateLimit(middlewares.RateLimitOptions{
+		Name:    "ip",
+		Limiter: ipLimiter,
+		Key:     keyByBasicAuth(middlewares.KeyByIP),
+		Refund:  isAuthenticated,
+	}))
+	authenticatedGroup.Use(middlewares.RateLimit(middlewares.RateLimitOptions{
+		Name:    "username",
+		Limiter: usernameLimiter,
+		Key:     keyByBasicAuthUsername,
+		Refund:  isAuthenticated,
+	}))
+	authenticatedGroup.Use(AuthMiddleware(
+		SessionCookieAuthenticator{Srv: authService, CookieSameSite: config.SessionCookieSameSite},
+		BearerAuthenticator{Srv: authService, Realm: "ready-set-go"},
+		BasicAuthenticator{Srv: authService, Realm: "ready-set-go"},
+	))
+	authenticatedGroup.Use(debugCapture.Middleware())
+	authenticatedGroup.Use(middlewares.RateLimit(middlewares.RateLimitOptions{
+		Name:    "user",
+		Limiter: &middlewares.TokenBucket{Limit: 600, Period: time.Minute, Burst: 100, Store: rateLimitStore},
+		Key:     keyByUserID,
+	}))
+	authenticatedGroup.RouteFunc("POST /logout", authHandlers.Logout)
+	authenticatedGroup.RouteFunc("POST /logout-all", authHandlers.LogoutAll)
+	authenticatedGroup.RouteFunc("POST /password", authHandlers.ChangePassword, credentialsBodySize)
+	authenticatedGroup.RouteFunc("GET /sessions", authHandlers.Sessions)
+	authenticatedGroup.RouteFunc("DELETE /sessions/{id}", authHandlers.RevokeSession)
+	authenticatedGroup.RouteFunc("GET /whoami", authHandlers.WhoAmI, middlewares.CacheControl("private, no-cache"))
+	authenticatedGroup.RouteFunc("GET /csrf-token", authHandlers.CSRFToken)
+
+	log.Printf("Listening on %s", config.Addr)
+
+	server := &http.Server{
+		Addr:              config.Addr,
+		Handler:           root.Mux(),
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
 
-	err = http.ListenAndServe(addr, root.Mux())
+	err = server.ListenAndServe()
 
 	if err != nil {
 		log.Fatal(err)
@@ -65,8 +279,181 @@ func main() {
 
 }
 
+// loadRuntimeConfig loads the files with settings that can change while the app is running.
+func loadRuntimeConfig(config Config, maintenance *middlewares.Maintenance, featureFlags *features.Flags) error {
+	if config.MaintenanceFile != "" {
+		err := maintenance.LoadFile(config.MaintenanceFile)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.FeatureFlagsFile != "" {
+		err := featureFlags.LoadFile(config.FeatureFlagsFile)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reloadRuntimeConfigOnSignal reloads the maintenance and feature flag files on SIGHUP.
+// The files replace changes made through the admin endpoints.
+func reloadRuntimeConfigOnSignal(config Config, maintenance *middlewares.Maintenance, featureFlags *features.Flags) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		err := loadRuntimeConfig(config, maintenance, featureFlags)
+
+		if err != nil {
+			log.Printf("Failed to reload config: %v", err)
+			continue
+		}
+
+		log.Printf("Reloaded config, changes made through the admin endpoints were replaced, maintenance mode is %s", maintenance.State().Mode)
+	}
+}
+
+// healthHandler reports whether the app can reach the database.
+func healthHandler(pool *pgxpool.Pool) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		err := pool.Ping(r.Context())
+
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"status": "unavailable"})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}
+}
+
+// runCommand runs one of the maintenance commands instead of the server,
+// e.g. `go run . gc-sessions`.
+func runCommand(ctx context.Context, command string, pool *pgxpool.Pool, q *generated.Queries, sessionJanitor *repositories.SessionJanitor) {
+	switch command {
+	case "gc-sessions":
+		deleted, err := sessionJanitor.DeleteExpired(ctx)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Printf("Deleted %d sessions", deleted)
+	case "normalize-usernames":
+		changed, err := repositories.NormalizeUsernames(ctx, pool, q)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Printf("Normalized %d usernames", changed)
+	default:
+		log.Fatalf("Unknown command %q, the commands are gc-sessions and normalize-usernames", command)
+	}
+}
+
+type expiringStore interface {
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// deleteExpiredPeriodically removes expired rows of a store from the database every interval.
+func deleteExpiredPeriodically(ctx context.Context, name string, store expiringStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := store.DeleteExpired(ctx)
+
+			if err != nil {
+				log.Printf("Failed to delete expired %s: %v", name, err)
+			}
+		}
+	}
+}
+
+// keyByUserID rate limits requests by the logged in user.
+func keyByUserID(r *http.Request) (string, error) {
+	user, ok := auth.UserFromContext(r.Context())
+
+	if !ok {
+		return "", nil
+	}
+
+	return strconv.Itoa(int(user.ID)), nil
+}
+
+// keyByBasicAuth limits requests with Basic credentials by key. Other requests aren't limited.
+func keyByBasicAuth(key middlewares.RateLimitKeyFunc) middlewares.RateLimitKeyFunc {
+	return func(r *http.Request) (string, error) {
+		if _, _, ok := r.BasicAuth(); !ok {
+			return "", nil
+		}
+
+		return key(r)
+	}
+}
+
+// keyByBasicAuthUsername limits requests by the folded username of their Basic credentials.
+func keyByBasicAuthUsername(r *http.Request) (string, error) {
+	username, _, ok := r.BasicAuth()
+
+	if !ok {
+		return "", nil
+	}
+
+	return auth.FoldUsername(username), nil
+}
+
+// isAuthenticated reports whether a response means the credentials were accepted.
+func isAuthenticated(status int) bool {
+	return status != http.StatusUnauthorized
+}
+
+// keyByFoldedUsername folds the username returned by key, so `Alice` and `ALICE` share a limit.
+func keyByFoldedUsername(key middlewares.RateLimitKeyFunc) middlewares.RateLimitKeyFunc {
+	return func(r *http.Request) (string, error) {
+		username, err := key(r)
+
+		if err != nil {
+			return "", err
+		}
+
+		return auth.FoldUsername(username), nil
+	}
+}
+
+// idempotencyScope separates the idempotency keys of logged in users.
+// Requests without a user, like registrations, are separated by IP.
+func idempotencyScope(r *http.Request) string {
+	key, _ := keyByUserID(r)
+
+	if key != "" {
+		return key
+	}
+
+	ip, _ := middlewares.KeyByIP(r)
+
+	return "ip:" + ip
+}
+
 type AuthHandlers struct {
-	Srv *auth.AuthService
+	Srv            *auth.AuthService
+	CookieSameSite http.SameSite
+	CSRFSecret     []byte
 }
 
 type LoginRequestBody struct {
@@ -79,6 +466,81 @@ type RegisterRequestBody struct {
 	Password string `json:"password"`
 }
 
+type ChangePasswordRequestBody struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// LoginResponseBody has the token of a new session, to be sent as a bearer token.
+type LoginResponseBody struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type SessionResponseBody struct {
+	ID           string    `json:"id"`
+	Device       string    `json:"device"`
+	UserAgent    string    `json:"user_agent"`
+	IP           string    `json:"ip"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Current      bool      `json:"current"`
+}
+
+// requestDevice describes the client that sent the request.
+func requestDevice(r *http.Request) auth.Device {
+	device := auth.Device{UserAgent: r.UserAgent()}
+
+	if ip := middlewares.ClientIP(r); ip.IsValid() {
+		device.IP = ip.String()
+	}
+
+	return device
+}
+
+// writeDecodeError responds with a 413 if the body was too large and a 400 otherwise.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if errors.As(err, &maxBytesErr) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	} else {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+
+	w.Write([]byte(err.Error()))
+}
+
+// writeAuthError maps the auth sentinel errors to status codes.
+// Anything else is logged and answered with a generic 500 so internals don't leak.
+func writeAuthError(w http.ResponseWriter, err error) {
+	var validationErr *auth.ValidationError
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case errors.As(err, &validationErr):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(validationErr)
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(auth.ErrInvalidCredentials.Error()))
+	case errors.Is(err, auth.ErrInvalidUsername):
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(auth.ErrInvalidUsername.Error()))
+	case errors.Is(err, auth.ErrUsernameTaken):
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(auth.ErrUsernameTaken.Error()))
+	default:
+		log.Printf("Auth error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
+	}
+}
+
 func (handler *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -87,9 +549,7 @@ func (handler *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&body)
 
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(err.Error()))
+		writeDecodeError(w, err)
 		return
 	}
 
@@ -99,36 +559,28 @@ func (handler *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(err.Error()))
+		writeAuthError(w, err)
 		return
 	}
 
-	session, err := handler.Srv.CreateSession(ctx, user)
+	var session *auth.Session
 
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
-		return
+	// A session the client already has could have been planted, so it is replaced.
+	if previous, cookieErr := r.Cookie("sessionID"); cookieErr == nil {
+		session, err = handler.Srv.RotateSession(ctx, auth.HashSessionToken(previous.Value), user, requestDevice(r))
+	} else {
+		session, err = handler.Srv.CreateSession(ctx, user, requestDevice(r))
 	}
 
-	cookie := &http.Cookie{
-		Name:     "sessionID",
-		Value:    session.ID,
-		Quoted:   false,
-		Expires:  session.ExpiresAt,
-		MaxAge:   int(session.ExpiresAt.Sub(time.Now()).Seconds()),
-		Secure:   true,
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
+	if err != nil {
+		writeAuthError(w, err)
+		return
 	}
 
-	http.SetCookie(w, cookie)
+	http.SetCookie(w, sessionCookie(*session, handler.CookieSameSite))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(session)
+	json.NewEncoder(w).Encode(LoginResponseBody{Token: session.Token, ExpiresAt: session.ExpiresAt})
 }
 
 func (handler *AuthHandlers) Register(w http.ResponseWriter, r *http.Request) {
@@ -139,9 +591,7 @@ func (handler *AuthHandlers) Register(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&body)
 
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(err.Error()))
+		writeDecodeError(w, err)
 		return
 	}
 
@@ -151,9 +601,7 @@ func (handler *AuthHandlers) Register(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
+		writeAuthError(w, err)
 		return
 	}
 
@@ -161,25 +609,198 @@ func (handler *AuthHandlers) Register(w http.ResponseWriter, r *http.Request) {
 }
 
 func (handler *AuthHandlers) Logout(w http.ResponseWriter, r *http.Request) {
-	sessionCookie := &http.Cookie{
+	authentication, _ := auth.FromContext(r.Context())
+
+	if authentication.SessionID != "" {
+		err := handler.Srv.RevokeSession(r.Context(), authentication.SessionID)
+
+		if err != nil {
+			writeAuthError(w, err)
+			return
+		}
+	}
+
+	handler.clearSessionCookie(w)
+	w.WriteHeader(http.StatusOK)
+}
+
+// LogoutAll revokes every session of the user. With `?except_current=true`
+// the session of the request stays logged in.
+func (handler *AuthHandlers) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	authentication, _ := auth.FromContext(r.Context())
+	exceptCurrent := ""
+
+	if r.URL.Query().Get("except_current") == "true" {
+		exceptCurrent = authentication.SessionID
+	}
+
+	revoked, err := handler.Srv.RevokeAllSessions(r.Context(), authentication.User.ID, exceptCurrent)
+
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	if exceptCurrent == "" {
+		handler.clearSessionCookie(w)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]int64{"revoked": revoked})
+}
+
+// sessionCookie stores the session ID in the browser until the session expires.
+func sessionCookie(session auth.Session, sameSite http.SameSite) *http.Cookie {
+	return &http.Cookie{
 		Name:     "sessionID",
-		Value:    "",
+		Value:    session.Token,
 		Quoted:   false,
-		Expires:  time.Time{},
-		MaxAge:   0,
+		Expires:  session.ExpiresAt,
+		MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
 		Secure:   true,
 		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
+		SameSite: sameSite,
 	}
+}
+
+// ChangePassword logs the user out everywhere and replaces the current session.
+func (handler *AuthHandlers) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	authentication, _ := auth.FromContext(r.Context())
 
-	// TODO: Revoke session
+	var body ChangePasswordRequestBody
 
-	http.SetCookie(w, sessionCookie)
+	err := json.NewDecoder(r.Body).Decode(&body)
+
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	session, err := handler.Srv.ChangePassword(r.Context(), auth.ChangePasswordParams{
+		User:            authentication.User,
+		CurrentPassword: body.CurrentPassword,
+		NewPassword:     body.NewPassword,
+		SessionID:       authentication.SessionID,
+		Device:          requestDevice(r),
+	})
+
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Wrong password"))
+		return
+	}
+
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	if session == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if authentication.Method == auth.MethodSession {
+		http.SetCookie(w, sessionCookie(*session, handler.CookieSameSite))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(LoginResponseBody{Token: session.Token, ExpiresAt: session.ExpiresAt})
+}
+
+// Sessions lists where the user is logged in.
+func (handler *AuthHandlers) Sessions(w http.ResponseWriter, r *http.Request) {
+	authentication, _ := auth.FromContext(r.Context())
+
+	sessions, err := handler.Srv.ListSessions(r.Context(), authentication.User.ID)
+
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	body := make([]SessionResponseBody, 0, len(sessions))
+
+	for _, session := range sessions {
+		body = append(body, SessionResponseBody{
+			ID:           session.PublicID,
+			Device:       session.DeviceLabel,
+			UserAgent:    session.UserAgent,
+			IP:           session.IP,
+			CreatedAt:    session.CreatedAt,
+			LastActiveAt: session.LastActiveAt,
+			ExpiresAt:    session.ExpiresAt,
+			Current:      session.ID == authentication.SessionID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(body)
+}
+
+// RevokeSession logs the user out of the session with the ID listed by [AuthHandlers.Sessions].
+func (handler *AuthHandlers) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	authentication, _ := auth.FromContext(r.Context())
+
+	err := handler.Srv.RevokeUserSession(r.Context(), authentication.User.ID, r.PathValue("id"))
+
+	if errors.Is(err, auth.ErrSessionNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clearSessionCookie tells the browser to delete the session cookie.
+func (handler *AuthHandlers) clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "sessionID",
+		Value:    "",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: handler.CookieSameSite,
+	})
 }
 
 func (handler *AuthHandlers) WhoAmI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(r.Context().Value("user"))
+	user, _ := auth.UserFromContext(r.Context())
+
+	json.NewEncoder(w).Encode(user)
+}
+
+// CSRFToken returns a token that has to be sent in the `X-CSRF-Token` header of unsafe requests.
+func (handler *AuthHandlers) CSRFToken(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := r.Cookie("sessionID")
+
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	token, err := middlewares.CSRFToken(handler.CSRFSecret, sessionID.Value)
+
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
 }
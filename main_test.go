@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/auth"
+	"github.com/dpbrackin/ready-set-go/features"
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginOnlyReturnsTokenAndExpiry(t *testing.T) {
+	srv, _, _ := newTestAuthService(t, auth.NewMemorySessionStore())
+	handlers := AuthHandlers{Srv: srv}
+
+	recorder := httptest.NewRecorder()
+	handlers.Login(recorder, httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"alice","password":"password"}`)))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var body map[string]any
+
+	err := json.Unmarshal(recorder.Body.Bytes(), &body)
+	assert.Nil(t, err)
+
+	assert.ElementsMatch(t, []string{"token", "expires_at"}, mapKeys(body))
+	assert.Equal(t, recorder.Result().Cookies()[0].Value, body["token"])
+}
+
+func TestLoginKeepsSessionsOfOtherUsers(t *testing.T) {
+	sessions := auth.NewMemorySessionStore()
+	srv, _, _ := newTestAuthService(t, sessions)
+	handlers := AuthHandlers{Srv: srv}
+
+	other, err := srv.CreateSession(context.Background(), auth.User{ID: 2, Username: "bob"}, auth.Device{})
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"alice","password":"password"}`))
+	req.AddCookie(&http.Cookie{Name: "sessionID", Value: other.Token})
+
+	recorder := httptest.NewRecorder()
+	handlers.Login(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	stored, err := sessions.GetSession(context.Background(), other.ID)
+	assert.Nil(t, err)
+	assert.False(t, stored.IsRevoked)
+}
+
+func TestLoginHidesInternalErrors(t *testing.T) {
+	srv, _, _ := newTestAuthService(t, failingSessionStore{auth.NewMemorySessionStore()})
+	handlers := AuthHandlers{Srv: srv}
+
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"alice","password":"password"}`))
+	req.AddCookie(&http.Cookie{Name: "sessionID", Value: "token"})
+
+	recorder := httptest.NewRecorder()
+	handlers.Login(recorder, req)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, http.StatusText(http.StatusInternalServerError), recorder.Body.String())
+}
+
+func TestIdempotencyScopeSeparatesAnonymousClients(t *testing.T) {
+	handler := middlewares.RealIP(middlewares.RealIPOptions{})(middlewares.Idempotency(middlewares.IdempotencyOptions{
+		Store: middlewares.NewMemoryIdempotencyStore(),
+		Scope: idempotencyScope,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	})))
+
+	for _, remoteAddr := range []string{"192.0.2.1:1234", "192.0.2.2:1234"} {
+		req := httptest.NewRequest("POST", "/register", strings.NewReader(remoteAddr))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Idempotency-Key", "key1")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, remoteAddr, recorder.Body.String())
+	}
+}
+
+func TestReloadReplacesAdminChanges(t *testing.T) {
+	dir := t.TempDir()
+	config := Config{
+		MaintenanceFile:  filepath.Join(dir, "maintenance.json"),
+		FeatureFlagsFile: filepath.Join(dir, "features.json"),
+	}
+
+	err := os.WriteFile(config.MaintenanceFile, []byte(`{"mode": "read-only"}`), 0o600)
+	assert.Nil(t, err)
+
+	err = os.WriteFile(config.FeatureFlagsFile, []byte(`{"new-ui": {"percentage": 100}}`), 0o600)
+	assert.Nil(t, err)
+
+	maintenance := middlewares.NewMaintenance()
+	featureFlags := features.NewFlags(nil)
+
+	err = maintenance.Set(middlewares.MaintenanceState{Mode: middlewares.MaintenanceFull})
+	assert.Nil(t, err)
+
+	featureFlags.Set("new-ui", features.Flag{})
+	featureFlags.Set("beta", features.Flag{Percentage: 100})
+
+	err = loadRuntimeConfig(config, maintenance, featureFlags)
+	assert.Nil(t, err)
+
+	assert.Equal(t, middlewares.MaintenanceReadOnly, maintenance.State().Mode)
+	assert.Equal(t, map[string]features.Flag{"new-ui": {Percentage: 100}}, featureFlags.All())
+}
+
+func mapKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
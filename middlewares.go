@@ -1,65 +1,202 @@
 package main
 
 import (
-	"context"
+	"crypto/subtle"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dpbrackin/ready-set-go/auth"
+	"github.com/dpbrackin/ready-set-go/middlewares"
 	"github.com/dpbrackin/ready-set-go/router"
 )
 
-type ResponseWritter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func (w *ResponseWritter) WriteHeader(code int) {
-	w.ResponseWriter.WriteHeader(code)
-	w.statusCode = code
-}
-
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		path := r.URL.Path
 
-		responseWriter := &ResponseWritter{
-			ResponseWriter: w,
+		responseWriter := router.WrapResponseWriter(w)
+
+		ctx, compression := middlewares.WithCompressionStats(r.Context())
+
+		next.ServeHTTP(responseWriter, r.WithContext(ctx))
+
+		status := responseWriter.Status()
+
+		// The server sends a 200 if the handler didn't write anything.
+		if status == 0 {
+			status = http.StatusOK
 		}
 
-		next.ServeHTTP(responseWriter, r)
+		clientIP := middlewares.ClientIP(r)
 
-		log.Printf("[%d] %v %v", responseWriter.statusCode, path, time.Since(start))
+		if compression.Encoding != "" {
+			log.Printf("[%d] %v %v %v %dB (%s, %dB uncompressed)", status, clientIP, path, time.Since(start), responseWriter.BytesWritten(), compression.Encoding, compression.UncompressedBytes)
+			return
+		}
+
+		log.Printf("[%d] %v %v %v %dB", status, clientIP, path, time.Since(start), responseWriter.BytesWritten())
 
 	})
 }
 
-// AuthMidleware checks for a loged in user and passes it into the context.
-// If their is no logged in user, it will reject the request with a 401.
-func AuthMiddleware(srv *auth.AuthService) router.Middleware {
+// Authenticator is a way to authenticate a request.
+type Authenticator interface {
+	// Authenticate returns false if the request doesn't carry credentials for
+	// this method, so the next authenticator can be tried. It can set headers,
+	// e.g. to renew a cookie.
+	Authenticate(w http.ResponseWriter, r *http.Request) (authentication auth.Authentication, ok bool, err error)
+	// Challenge is the `WWW-Authenticate` challenge of the method, if it has one.
+	Challenge() string
+}
+
+// AuthMiddleware tries the authenticators in order and passes the user of the
+// first one that finds credentials into the context. If none do or the
+// credentials are invalid, it rejects the request with a 401 that challenges
+// the client to use any of the methods.
+func AuthMiddleware(authenticators ...Authenticator) router.Middleware {
+	var challenges []string
+
+	for _, authenticator := range authenticators {
+		if challenge := authenticator.Challenge(); challenge != "" {
+			challenges = append(challenges, challenge)
+		}
+	}
+
+	challenge := strings.Join(challenges, ", ")
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			sessionID, err := r.Cookie("sessionID")
+			for _, authenticator := range authenticators {
+				authentication, ok, err := authenticator.Authenticate(w, r)
 
-			if err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte(err.Error()))
+				if !ok {
+					continue
+				}
+
+				if err != nil && !auth.IsUnauthenticated(err) {
+					log.Printf("Failed to authenticate: %v", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+
+				if err != nil {
+					break
+				}
+
+				ctx := auth.WithAuthentication(r.Context(), authentication)
+
+				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
 
-			user, err := srv.AuthenticateSession(r.Context(), sessionID.Value)
+			if challenge != "" {
+				w.Header().Set("WWW-Authenticate", challenge)
+			}
 
-			if err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte(err.Error()))
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
+
+// SessionCookieAuthenticator authenticates browsers with the `sessionID` cookie.
+// The cookie is sent again when the session is renewed.
+type SessionCookieAuthenticator struct {
+	Srv            *auth.AuthService
+	CookieSameSite http.SameSite
+}
+
+func (a SessionCookieAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request) (auth.Authentication, bool, error) {
+	sessionID, err := r.Cookie("sessionID")
+
+	if err != nil {
+		return auth.Authentication{}, false, nil
+	}
+
+	session, renewed, err := a.Srv.AuthenticateSession(r.Context(), sessionID.Value)
+
+	if err == nil && renewed {
+		http.SetCookie(w, sessionCookie(session, a.CookieSameSite))
+	}
+
+	return auth.Authentication{User: session.User, Method: auth.MethodSession, SessionID: session.ID}, true, err
+}
+
+func (a SessionCookieAuthenticator) Challenge() string {
+	return ""
+}
+
+// BearerAuthenticator authenticates API clients that send the session token
+// returned by `/login` as a bearer token.
+type BearerAuthenticator struct {
+	Srv   *auth.AuthService
+	Realm string
+}
+
+func (a BearerAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request) (auth.Authentication, bool, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+
+	if !strings.EqualFold(scheme, "Bearer") {
+		return auth.Authentication{}, false, nil
+	}
+
+	token = strings.TrimSpace(token)
+	session, _, err := a.Srv.AuthenticateSession(r.Context(), token)
+
+	return auth.Authentication{User: session.User, Method: auth.MethodBearer, SessionID: session.ID}, true, err
+}
+
+func (a BearerAuthenticator) Challenge() string {
+	return fmt.Sprintf("Bearer realm=%q", a.Realm)
+}
+
+// BasicAuthenticator authenticates machine users with HTTP Basic credentials.
+type BasicAuthenticator struct {
+	Srv   *auth.AuthService
+	Realm string
+}
+
+func (a BasicAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request) (auth.Authentication, bool, error) {
+	username, password, ok := r.BasicAuth()
+
+	if !ok {
+		return auth.Authentication{}, false, nil
+	}
+
+	user, err := a.Srv.AuthenticateWithPassword(r.Context(), auth.PasswordCredentials{
+		Username: username,
+		Password: password,
+	})
+
+	return auth.Authentication{User: user, Method: auth.MethodBasic}, true, err
+}
+
+func (a BasicAuthenticator) Challenge() string {
+	return fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, a.Realm)
+}
+
+// AdminMiddleware only lets requests with the admin token through.
+// If no token is configured, admin routes respond with a 404.
+func AdminMiddleware(token string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				http.NotFound(w, r)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), "user", user)
+			bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+			if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
 
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r)
 		})
 	}
 }
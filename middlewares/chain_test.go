@@ -0,0 +1,78 @@
+package middlewares_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/dpbrackin/ready-set-go/router"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestChainKeepsOptionalInterfaces runs a handler behind the root middlewares of the app,
+// to catch writers that hide [http.Flusher] or [http.Hijacker], e.g. by embedding [http.ResponseWriter].
+func TestChainKeepsOptionalInterfaces(t *testing.T) {
+	debugCapture := middlewares.NewDebugCapture(middlewares.DebugCaptureOptions{})
+	token, err := debugCapture.IssueToken(time.Minute)
+	assert.Nil(t, err)
+
+	root := router.NewRootRouter()
+	root.Use(middlewares.RealIP(middlewares.RealIPOptions{}))
+	root.Use(middlewares.NewFaultInjector().Middleware())
+	root.Use(middlewares.MaxBodySize(1 << 20))
+	root.Use(middlewares.Timeout(middlewares.TimeoutOptions{Timeout: time.Minute}))
+	root.Use(middlewares.SecurityHeaders(middlewares.DefaultSecurityHeadersOptions()))
+	root.Use(middlewares.Compress(middlewares.CompressOptions{}))
+	root.Use(middlewares.ETag(middlewares.ETagOptions{}))
+	root.Use(middlewares.CORS(middlewares.CORSOptions{AllowedOrigins: []string{"*"}}))
+	root.Use(debugCapture.Middleware())
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, isFlusher := w.(http.Flusher)
+		_, isHijacker := w.(http.Hijacker)
+
+		conn, buf, err := http.NewResponseController(w).Hijack()
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		defer conn.Close()
+
+		body := fmt.Sprintf("flusher=%t hijacker=%t", isFlusher, isHijacker)
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+		buf.Flush()
+	}
+
+	root.RouteFunc("GET /chain", handler)
+	root.RouteFunc("POST /chain", handler, middlewares.Idempotency(middlewares.IdempotencyOptions{
+		Store: middlewares.NewMemoryIdempotencyStore(),
+	}))
+
+	server := httptest.NewServer(root.Mux())
+	defer server.Close()
+
+	for _, method := range []string{"GET", "POST"} {
+		req, err := http.NewRequest(method, server.URL+"/chain", strings.NewReader(""))
+		assert.Nil(t, err)
+
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("Idempotency-Key", "key1")
+		req.Header.Set(middlewares.DebugCaptureHeader, token)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "flusher=true hijacker=true", string(body), method)
+	}
+}
@@ -0,0 +1,331 @@
+package middlewares
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// CompressOptions configures the [Compress] middleware.
+type CompressOptions struct {
+	// Level defaults to [gzip.DefaultCompression] if nil.
+	Level *int
+	// MinSize is the smallest response that is compressed. Defaults to 1024 bytes.
+	MinSize int
+	// ContentTypes lists the media types that are compressed.
+	// Entries ending in `/` match every subtype, e.g. `text/`.
+	ContentTypes []string
+}
+
+// DefaultCompressContentTypes are the content types compressed if [CompressOptions.ContentTypes] is empty.
+var DefaultCompressContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// CompressionStats reports what the [Compress] middleware did with a response.
+type CompressionStats struct {
+	// Encoding is empty if the response was not compressed.
+	Encoding          string
+	UncompressedBytes int64
+}
+
+type compressionStatsKey struct{}
+
+// WithCompressionStats returns a context that [Compress] reports its stats to.
+// Middlewares running before Compress can use it to log the uncompressed size of responses.
+func WithCompressionStats(ctx context.Context) (context.Context, *CompressionStats) {
+	stats := &CompressionStats{}
+
+	return context.WithValue(ctx, compressionStatsKey{}, stats), stats
+}
+
+type resettableWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+type compressor struct {
+	options CompressOptions
+	pools   map[string]*sync.Pool
+}
+
+// Compress compresses responses with gzip or deflate, depending on the `Accept-Encoding` header.
+//
+// Responses are buffered until MinSize bytes are written. Flushing a response,
+// e.g. for server sent events, compresses it right away if the content type allows it.
+func Compress(options CompressOptions) router.Middleware {
+	level := gzip.DefaultCompression
+
+	if options.Level != nil {
+		level = *options.Level
+	}
+
+	if options.MinSize == 0 {
+		options.MinSize = 1024
+	}
+
+	if len(options.ContentTypes) == 0 {
+		options.ContentTypes = DefaultCompressContentTypes
+	}
+
+	c := &compressor{
+		options: options,
+		pools: map[string]*sync.Pool{
+			"gzip": {New: func() any {
+				w, _ := gzip.NewWriterLevel(io.Discard, level)
+				return w
+			}},
+			"deflate": {New: func() any {
+				w, _ := flate.NewWriter(io.Discard, level)
+				return w
+			}},
+		},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				compressor:     c,
+				encoding:       encoding,
+				status:         http.StatusOK,
+			}
+
+			cw.stats, _ = r.Context().Value(compressionStatsKey{}).(*CompressionStats)
+
+			defer cw.close()
+
+			next.ServeHTTP(router.WrapResponseWriterWithHooks(w, router.Hooks{
+				WriteHeader: cw.WriteHeader,
+				Write:       cw.Write,
+				Flush:       cw.Flush,
+			}), r)
+		})
+	}
+}
+
+func (c *compressor) isCompressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	for _, allowed := range c.options.ContentTypes {
+		if mediaType == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// negotiateEncoding picks gzip or deflate, whichever has the higher q-value.
+// `*` only applies to encodings that aren't listed, and a q-value of 0 refuses an encoding.
+func negotiateEncoding(acceptEncoding string) string {
+	qValues := map[string]float64{}
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+
+			if err != nil {
+				continue
+			}
+
+			q = parsed
+		}
+
+		qValues[name] = q
+	}
+
+	best, bestQ := "", 0.0
+
+	// gzip comes first, so it wins ties.
+	for _, name := range []string{"gzip", "deflate"} {
+		q, ok := qValues[name]
+
+		if !ok {
+			q = qValues["*"]
+		}
+
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	compressor *compressor
+	encoding   string
+	stats      *CompressionStats
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	writer      resettableWriter
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+
+	// Informational responses are sent right away and don't start the response.
+	if code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.wroteHeader = true
+	w.status = code
+
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+
+	if w.stats != nil {
+		w.stats.UncompressedBytes += int64(len(p))
+	}
+
+	if w.decided {
+		if w.writer != nil {
+			return w.writer.Write(p)
+		}
+
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+
+	if len(w.buf) >= w.compressor.options.MinSize {
+		err := w.decide(w.shouldCompress())
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush compresses the response if the content type allows it, ignoring MinSize.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(w.shouldCompress())
+	}
+
+	if w.writer != nil {
+		w.writer.Flush()
+	}
+
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	headers := w.Header()
+
+	if headers.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := headers.Get("Content-Type")
+
+	if contentType == "" && len(w.buf) > 0 {
+		contentType = http.DetectContentType(w.buf)
+		headers.Set("Content-Type", contentType)
+	}
+
+	return w.compressor.isCompressible(contentType)
+}
+
+// decide sends the header and the buffered body, compressed or not.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+
+	if compress {
+		headers := w.Header()
+		headers.Set("Content-Encoding", w.encoding)
+		headers.Del("Content-Length")
+		headers.Del("Accept-Ranges")
+
+		// A strong ETag would be wrong for the compressed body.
+		if etag := headers.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			headers.Set("ETag", "W/"+etag)
+		}
+
+		w.writer = w.compressor.pools[w.encoding].Get().(resettableWriter)
+		w.writer.Reset(w.ResponseWriter)
+
+		if w.stats != nil {
+			w.stats.Encoding = w.encoding
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+
+	if w.writer != nil {
+		_, err = w.writer.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		if !w.wroteHeader {
+			// Nothing was written, let the server send its default response.
+			return
+		}
+
+		w.decide(false)
+	}
+
+	if w.writer != nil {
+		w.writer.Close()
+		w.writer.Reset(io.Discard)
+		w.compressor.pools[w.encoding].Put(w.writer)
+		w.writer = nil
+	}
+}
@@ -0,0 +1,130 @@
+package middlewares_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+var largeBody = strings.Repeat("Lorem ipsum dolor sit amet. ", 100)
+
+func textHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(body))
+	})
+}
+
+func TestCompressGzip(t *testing.T) {
+	ctx, stats := middlewares.WithCompressionStats(context.Background())
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	req.Header.Set("Accept-Encoding", "deflate;q=0.5, gzip")
+
+	recorder := httptest.NewRecorder()
+	middlewares.Compress(middlewares.CompressOptions{})(textHandler(largeBody)).ServeHTTP(recorder, req)
+
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", recorder.Header().Get("Vary"))
+	assert.Equal(t, "gzip", stats.Encoding)
+	assert.Equal(t, int64(len(largeBody)), stats.UncompressedBytes)
+
+	reader, err := gzip.NewReader(recorder.Body)
+	assert.Nil(t, err)
+
+	body, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, largeBody, string(body))
+}
+
+func TestCompressNegotiatesEncoding(t *testing.T) {
+	tests := map[string]string{
+		"gzip":                      "gzip",
+		"deflate":                   "deflate",
+		"deflate, gzip":             "gzip",
+		"deflate;q=0.5, gzip":       "gzip",
+		"gzip;q=0.5, deflate":       "deflate",
+		"*":                         "gzip",
+		"gzip;q=0, *":               "deflate",
+		"gzip;q=0, deflate;q=0, *":  "",
+		"*;q=0, deflate":            "deflate",
+		"*;q=0":                     "",
+		"identity":                  "",
+		"br, gzip;q=0.1, *;q=0.5":   "deflate",
+		"gzip;q=0, deflate;q=0.001": "deflate",
+	}
+
+	for acceptEncoding, encoding := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+
+		recorder := httptest.NewRecorder()
+		middlewares.Compress(middlewares.CompressOptions{})(textHandler(largeBody)).ServeHTTP(recorder, req)
+
+		assert.Equal(t, encoding, recorder.Header().Get("Content-Encoding"), acceptEncoding)
+	}
+}
+
+func TestCompressNoCompressionLevel(t *testing.T) {
+	level := gzip.NoCompression
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	recorder := httptest.NewRecorder()
+	middlewares.Compress(middlewares.CompressOptions{Level: &level})(textHandler(largeBody)).ServeHTTP(recorder, req)
+
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+	// Stored blocks are larger than the body.
+	assert.Greater(t, recorder.Body.Len(), len(largeBody))
+}
+
+func TestCompressSkipsSmallResponses(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	recorder := httptest.NewRecorder()
+	middlewares.Compress(middlewares.CompressOptions{})(textHandler("small")).ServeHTTP(recorder, req)
+
+	assert.Equal(t, "", recorder.Header().Get("Content-Encoding"))
+	assert.Equal(t, "small", recorder.Body.String())
+}
+
+func TestCompressSkipsDisallowedContentTypes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(largeBody))
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	recorder := httptest.NewRecorder()
+	middlewares.Compress(middlewares.CompressOptions{})(handler).ServeHTTP(recorder, req)
+
+	assert.Equal(t, "", recorder.Header().Get("Content-Encoding"))
+	assert.Equal(t, largeBody, recorder.Body.String())
+}
+
+func TestCompressFlush(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	recorder := httptest.NewRecorder()
+	middlewares.Compress(middlewares.CompressOptions{})(handler).ServeHTTP(recorder, req)
+
+	assert.True(t, recorder.Flushed)
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+}
@@ -0,0 +1,197 @@
+// Package middlewares implements reusable [router.Middleware]s.
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// CORSOptions configures the [CORS] middleware.
+type CORSOptions struct {
+	// AllowedOrigins lists the origins that are allowed to make cross origin requests.
+	// An origin can contain a single `*` wildcard, e.g. `https://*.example.com`.
+	// A lone `*` allows every origin.
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, HEAD and POST.
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers a client may send.
+	// A lone `*` allows every header.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers a client may read.
+	ExposedHeaders []string
+	// AllowCredentials allows cookies to be sent with cross origin requests.
+	AllowCredentials bool
+	// MaxAge is how long the result of a preflight request can be cached.
+	MaxAge time.Duration
+}
+
+type cors struct {
+	origins        []string
+	allowAll       bool
+	methods        []string
+	headers        []string
+	allowAllHeader bool
+	options        CORSOptions
+}
+
+// CORS answers preflight requests and adds CORS headers to responses.
+// Preflight requests are answered by the middleware and never reach the handler,
+// so it works with the OPTIONS routes generated by the router.
+func CORS(options CORSOptions) router.Middleware {
+	c := &cors{
+		methods: []string{http.MethodGet, http.MethodHead, http.MethodPost},
+		options: options,
+	}
+
+	for _, origin := range options.AllowedOrigins {
+		if origin == "*" {
+			c.allowAll = true
+			continue
+		}
+
+		c.origins = append(c.origins, strings.ToLower(origin))
+	}
+
+	if len(options.AllowedMethods) > 0 {
+		c.methods = make([]string, 0, len(options.AllowedMethods))
+
+		for _, method := range options.AllowedMethods {
+			c.methods = append(c.methods, strings.ToUpper(method))
+		}
+	}
+
+	for _, header := range options.AllowedHeaders {
+		if header == "*" {
+			c.allowAllHeader = true
+			continue
+		}
+
+		c.headers = append(c.headers, http.CanonicalHeaderKey(header))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+
+			if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+				c.preflight(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+
+			if origin != "" && c.isOriginAllowed(origin) {
+				c.setOriginHeaders(w, origin)
+
+				if len(options.ExposedHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(options.ExposedHeaders, ", "))
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	headers := w.Header()
+	headers.Add("Vary", "Origin")
+	headers.Add("Vary", "Access-Control-Request-Method")
+	headers.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := r.Header.Get("Origin")
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requestedHeaders := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+
+	if !c.isOriginAllowed(origin) || !slices.Contains(c.methods, method) || !c.areHeadersAllowed(requestedHeaders) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	c.setOriginHeaders(w, origin)
+	headers.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+
+	if len(requestedHeaders) > 0 {
+		headers.Set("Access-Control-Allow-Headers", strings.Join(requestedHeaders, ", "))
+	}
+
+	if c.options.MaxAge > 0 {
+		headers.Set("Access-Control-Max-Age", strconv.Itoa(int(c.options.MaxAge.Seconds())))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *cors) setOriginHeaders(w http.ResponseWriter, origin string) {
+	// The wildcard can not be used together with credentials, so the origin is echoed instead.
+	if c.allowAll && !c.options.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if c.options.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) isOriginAllowed(origin string) bool {
+	if c.allowAll {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+
+	for _, allowed := range c.origins {
+		if matchWildcard(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *cors) areHeadersAllowed(headers []string) bool {
+	if c.allowAllHeader {
+		return true
+	}
+
+	for _, header := range headers {
+		if !slices.Contains(c.headers, header) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchWildcard matches s against a pattern containing at most one `*`.
+func matchWildcard(pattern, s string) bool {
+	prefix, suffix, found := strings.Cut(pattern, "*")
+
+	if !found {
+		return pattern == s
+	}
+
+	return len(s) >= len(prefix)+len(suffix) && strings.HasPrefix(s, prefix) && strings.HasSuffix(s, suffix)
+}
+
+// parseHeaderList parses a comma separated list of header names.
+func parseHeaderList(list string) []string {
+	headers := make([]string, 0)
+
+	for _, header := range strings.Split(list, ",") {
+		header = strings.TrimSpace(header)
+
+		if header != "" {
+			headers = append(headers, http.CanonicalHeaderKey(header))
+		}
+	}
+
+	return headers
+}
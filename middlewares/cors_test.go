@@ -0,0 +1,76 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/dpbrackin/ready-set-go/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+func newCORSMux() *http.ServeMux {
+	root := router.NewRootRouter()
+	root.Use(middlewares.CORS(middlewares.CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.preview.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           time.Minute,
+	}))
+	root.RouteFunc("POST /login", okHandler)
+
+	return root.Mux()
+}
+
+func TestCORSPreflight(t *testing.T) {
+	mux := newCORSMux()
+
+	req := httptest.NewRequest("OPTIONS", "/login", nil)
+	req.Header.Set("Origin", "https://pr-1.preview.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "content-type")
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Equal(t, "https://pr-1.preview.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", recorder.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, POST", recorder.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type", recorder.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "60", recorder.Header().Get("Access-Control-Max-Age"))
+}
+
+func TestCORSPreflightRejectsUnknownOrigin(t *testing.T) {
+	mux := newCORSMux()
+
+	req := httptest.NewRequest("OPTIONS", "/login", nil)
+	req.Header.Set("Origin", "https://evil.example.org")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+
+	assert.Equal(t, "", recorder.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	mux := newCORSMux()
+
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "https://app.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", recorder.Header().Get("Vary"))
+}
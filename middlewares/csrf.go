@@ -0,0 +1,173 @@
+package middlewares
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+const csrfSaltLength = 16
+
+// CSRFOptions configures the [CSRF] middleware.
+type CSRFOptions struct {
+	// Secret is used to sign tokens. It has to be the same on every instance.
+	Secret []byte
+	// SessionCookie is the name of the cookie holding the session ID. Tokens are bound to it.
+	// If it is empty, only the origin of requests is checked.
+	SessionCookie string
+	// HeaderName is the header the token is read from. Defaults to `X-CSRF-Token`.
+	HeaderName string
+	// TrustedOrigins lists origins, besides the request's own, that may make unsafe requests.
+	// An origin can contain a single `*` wildcard, e.g. `https://*.example.com`.
+	TrustedOrigins []string
+}
+
+// CSRF protects cookie authenticated requests against cross site request forgery.
+//
+// Unsafe requests are rejected with a 403 if the browser reports that they come
+// from an untrusted origin. If the request carries a session cookie it also needs
+// a token created by [CSRFToken] for that session.
+// Requests authenticated with an `Authorization: Bearer` header and no session
+// cookie are exempt, since browsers never attach that header on their own.
+func CSRF(options CSRFOptions) router.Middleware {
+	if options.HeaderName == "" {
+		options.HeaderName = "X-CSRF-Token"
+	}
+
+	trustedOrigins := make([]string, 0, len(options.TrustedOrigins))
+
+	for _, origin := range options.TrustedOrigins {
+		trustedOrigins = append(trustedOrigins, strings.ToLower(origin))
+	}
+
+	isTrusted := func(origin string) bool {
+		origin = strings.ToLower(origin)
+
+		for _, trusted := range trustedOrigins {
+			if matchWildcard(trusted, origin) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var sessionCookie *http.Cookie
+			hasSession := false
+
+			if options.SessionCookie != "" {
+				cookie, err := r.Cookie(options.SessionCookie)
+				sessionCookie, hasSession = cookie, err == nil && cookie.Value != ""
+			}
+
+			if !hasSession && isBearerAuthenticated(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !isSameOrigin(r) {
+				origin := r.Header.Get("Origin")
+
+				if origin == "" || !isTrusted(origin) {
+					http.Error(w, "Cross origin request rejected", http.StatusForbidden)
+					return
+				}
+			}
+
+			if hasSession && !ValidCSRFToken(options.Secret, sessionCookie.Value, r.Header.Get(options.HeaderName)) {
+				http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CSRFToken creates a new token for the session.
+// Every call returns a different token so it can not be recovered from compressed responses.
+func CSRFToken(secret []byte, sessionID string) (string, error) {
+	salt := make([]byte, csrfSaltLength)
+
+	_, err := rand.Read(salt)
+
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(append(salt, csrfSignature(secret, salt, sessionID)...)), nil
+}
+
+// ValidCSRFToken reports whether token was created by [CSRFToken] for the session.
+func ValidCSRFToken(secret []byte, sessionID string, token string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil || len(decoded) != csrfSaltLength+sha256.Size {
+		return false
+	}
+
+	salt, signature := decoded[:csrfSaltLength], decoded[csrfSaltLength:]
+
+	return hmac.Equal(signature, csrfSignature(secret, salt, sessionID))
+}
+
+func csrfSignature(secret []byte, salt []byte, sessionID string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(salt)
+	mac.Write([]byte(sessionID))
+
+	return mac.Sum(nil)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func isBearerAuthenticated(r *http.Request) bool {
+	scheme, _, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+
+	return strings.EqualFold(scheme, "Bearer")
+}
+
+// isSameOrigin checks the fetch metadata and Origin headers set by browsers.
+// Requests without either header don't come from a browser and are let through.
+func isSameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "same-site", "cross-site":
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,81 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+var csrfSecret = []byte("secret")
+
+func newCSRFHandler() http.Handler {
+	return middlewares.CSRF(middlewares.CSRFOptions{
+		Secret:         csrfSecret,
+		SessionCookie:  "sessionID",
+		TrustedOrigins: []string{"https://app.example.com"},
+	})(http.HandlerFunc(okHandler))
+}
+
+func TestCSRFRejectsMissingToken(t *testing.T) {
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "sessionID", Value: "session1"})
+
+	recorder := httptest.NewRecorder()
+	newCSRFHandler().ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestCSRFAcceptsSessionToken(t *testing.T) {
+	token, err := middlewares.CSRFToken(csrfSecret, "session1")
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "sessionID", Value: "session1"})
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("X-CSRF-Token", token)
+
+	recorder := httptest.NewRecorder()
+	newCSRFHandler().ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestCSRFRejectsTokenOfOtherSession(t *testing.T) {
+	token, err := middlewares.CSRFToken(csrfSecret, "session2")
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "sessionID", Value: "session1"})
+	req.Header.Set("X-CSRF-Token", token)
+
+	recorder := httptest.NewRecorder()
+	newCSRFHandler().ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestCSRFRejectsCrossSiteRequest(t *testing.T) {
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.Header.Set("Origin", "https://evil.example.org")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+
+	recorder := httptest.NewRecorder()
+	newCSRFHandler().ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestCSRFExemptsBearerRequests(t *testing.T) {
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Origin", "https://evil.example.org")
+
+	recorder := httptest.NewRecorder()
+	newCSRFHandler().ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
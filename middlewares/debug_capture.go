@@ -0,0 +1,657 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// DebugCaptureHeader carries a token issued by [DebugCapture.IssueToken] to capture a request.
+const DebugCaptureHeader = "X-Debug-Capture"
+
+const redacted = "[REDACTED]"
+
+// DebugCaptureOptions configures a [DebugCapture].
+type DebugCaptureOptions struct {
+	// Capacity is how many exchanges are kept. Defaults to 100.
+	Capacity int
+	// MaxBodySize is how much of each body is kept. Defaults to 64 KiB.
+	MaxBodySize int
+	// RedactHeaders are replaced in captures, in addition to credentials and cookies.
+	RedactHeaders []string
+	// RedactFields are replaced in query strings, JSON and form bodies, in addition to passwords and tokens.
+	RedactFields []string
+	// Subject returns who made the request, e.g. the user ID.
+	Subject func(r *http.Request) string
+}
+
+// DebugCaptureRules select the requests that are captured.
+type DebugCaptureRules struct {
+	// Subjects are matched against [DebugCaptureOptions.Subject].
+	Subjects []string `json:"subjects"`
+	// Routes are matched against the route pattern, e.g. `POST /login`.
+	Routes []string `json:"routes"`
+}
+
+type CapturedRequest struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Proto         string      `json:"proto"`
+	Header        http.Header `json:"header"`
+	Body          string      `json:"body"`
+	BodySize      int64       `json:"body_size"`
+	BodyTruncated bool        `json:"body_truncated"`
+}
+
+type CapturedResponse struct {
+	Status        int         `json:"status"`
+	Header        http.Header `json:"header"`
+	Body          string      `json:"body"`
+	BodySize      int64       `json:"body_size"`
+	BodyTruncated bool        `json:"body_truncated"`
+}
+
+// CapturedExchange is a redacted request and its response.
+type CapturedExchange struct {
+	ID        string           `json:"id"`
+	StartedAt time.Time        `json:"started_at"`
+	Duration  time.Duration    `json:"duration"`
+	Route     string           `json:"route"`
+	Subject   string           `json:"subject"`
+	Request   CapturedRequest  `json:"request"`
+	Response  CapturedResponse `json:"response"`
+}
+
+// DebugCapture records requests and responses into a bounded ring buffer,
+// so client bug reports can be reproduced. Nothing is captured unless a
+// request matches the rules or carries a valid [DebugCaptureHeader] token.
+type DebugCapture struct {
+	options       DebugCaptureOptions
+	redactHeaders []string
+	redactFields  []string
+
+	mu        sync.Mutex
+	rules     DebugCaptureRules
+	tokens    map[string]time.Time
+	exchanges []CapturedExchange
+	next      int
+}
+
+func NewDebugCapture(options DebugCaptureOptions) *DebugCapture {
+	if options.Capacity == 0 {
+		options.Capacity = 100
+	}
+
+	if options.MaxBodySize == 0 {
+		options.MaxBodySize = 64 << 10
+	}
+
+	redactHeaders := []string{"Authorization", "Cookie", "Set-Cookie", "X-Csrf-Token", "Proxy-Authorization", DebugCaptureHeader}
+
+	for _, header := range options.RedactHeaders {
+		redactHeaders = append(redactHeaders, http.CanonicalHeaderKey(header))
+	}
+
+	redactFields := []string{"password", "token", "secret"}
+
+	for _, field := range options.RedactFields {
+		redactFields = append(redactFields, strings.ToLower(field))
+	}
+
+	return &DebugCapture{
+		options:       options,
+		redactHeaders: redactHeaders,
+		redactFields:  redactFields,
+		tokens:        make(map[string]time.Time),
+		exchanges:     make([]CapturedExchange, 0, options.Capacity),
+	}
+}
+
+func (d *DebugCapture) SetRules(rules DebugCaptureRules) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rules = rules
+}
+
+func (d *DebugCapture) Rules() DebugCaptureRules {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.rules
+}
+
+// IssueToken creates a token that captures every request sending it in the
+// [DebugCaptureHeader] header until ttl passes.
+func (d *DebugCapture) IssueToken(ttl time.Duration) (string, error) {
+	token, err := randomHex(16)
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for token, expiresAt := range d.tokens {
+		if now.After(expiresAt) {
+			delete(d.tokens, token)
+		}
+	}
+
+	d.tokens[token] = now.Add(ttl)
+
+	return token, nil
+}
+
+// Exchanges returns the captured exchanges, oldest first.
+func (d *DebugCapture) Exchanges() []CapturedExchange {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.exchanges) < d.options.Capacity {
+		return slices.Clone(d.exchanges)
+	}
+
+	return slices.Concat(d.exchanges[d.next:], d.exchanges[:d.next])
+}
+
+func (d *DebugCapture) add(exchange CapturedExchange) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.exchanges) < d.options.Capacity {
+		d.exchanges = append(d.exchanges, exchange)
+		return
+	}
+
+	d.exchanges[d.next] = exchange
+	d.next = (d.next + 1) % d.options.Capacity
+}
+
+func (d *DebugCapture) shouldCapture(r *http.Request, subject string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if token := r.Header.Get(DebugCaptureHeader); token != "" {
+		for issued, expiresAt := range d.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(issued)) == 1 && time.Now().Before(expiresAt) {
+				return true
+			}
+		}
+	}
+
+	return (subject != "" && slices.Contains(d.rules.Subjects, subject)) || slices.Contains(d.rules.Routes, r.Pattern)
+}
+
+// Middleware captures matching requests. Use it after the middleware that
+// authenticates users, so [DebugCaptureOptions.Subject] can see them.
+func (d *DebugCapture) Middleware() router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			subject := ""
+
+			if d.options.Subject != nil {
+				subject = d.options.Subject(r)
+			}
+
+			if !d.shouldCapture(r, subject) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, err := randomHex(8)
+
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			requestBody := &limitedBuffer{limit: d.options.MaxBodySize}
+
+			if r.Body != nil {
+				r.Body = readCloser{io.TeeReader(r.Body, requestBody), r.Body}
+			}
+
+			responseBody := &limitedBuffer{limit: d.options.MaxBodySize}
+			rw := router.WrapResponseWriterWithHooks(w, router.Hooks{
+				Write: (&captureWriter{w: w, body: responseBody}).Write,
+			})
+
+			defer func() {
+				status := rw.Status()
+
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				d.add(CapturedExchange{
+					ID:        id,
+					StartedAt: start,
+					Duration:  time.Since(start),
+					Route:     r.Pattern,
+					Subject:   subject,
+					Request: CapturedRequest{
+						Method:        r.Method,
+						URL:           d.requestURL(r),
+						Proto:         r.Proto,
+						Header:        d.redactHeader(r.Header),
+						Body:          d.redactBody(r.Header.Get("Content-Type"), requestBody.buf.Bytes(), requestBody.truncated),
+						BodySize:      requestBody.size,
+						BodyTruncated: requestBody.truncated,
+					},
+					Response: CapturedResponse{
+						Status:        status,
+						Header:        d.redactHeader(w.Header()),
+						Body:          d.redactBody(w.Header().Get("Content-Type"), responseBody.buf.Bytes(), responseBody.truncated),
+						BodySize:      responseBody.size,
+						BodyTruncated: responseBody.truncated,
+					},
+				})
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+
+	_, err := rand.Read(b)
+
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// requestURL returns the absolute URL of r, with sensitive query parameters redacted.
+func (d *DebugCapture) requestURL(r *http.Request) string {
+	u := *r.URL
+
+	if u.RawQuery != "" {
+		values, err := url.ParseQuery(u.RawQuery)
+
+		if err != nil {
+			u.RawQuery = redacted
+		} else if d.redactValues(values) {
+			u.RawQuery = values.Encode()
+		}
+	}
+
+	if u.Scheme == "" {
+		u.Scheme = "http"
+
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+
+	return u.String()
+}
+
+func (d *DebugCapture) redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+
+	for _, key := range d.redactHeaders {
+		if _, ok := header[key]; ok {
+			header[key] = []string{redacted}
+		}
+	}
+
+	return header
+}
+
+// redactBody replaces sensitive fields of JSON and form bodies.
+// Other bodies are kept as they are.
+func (d *DebugCapture) redactBody(contentType string, body []byte, truncated bool) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+
+	switch strings.TrimSpace(mediaType) {
+	case "application/json":
+		var value any
+
+		if truncated || json.Unmarshal(body, &value) != nil {
+			// A body that can't be parsed could still contain secrets.
+			if slices.ContainsFunc(d.redactFields, func(field string) bool {
+				return bytes.Contains(bytes.ToLower(body), []byte(field))
+			}) {
+				return redacted
+			}
+
+			return string(body)
+		}
+
+		redactedBody, err := json.Marshal(d.redactValue(value))
+
+		if err != nil {
+			return redacted
+		}
+
+		return string(redactedBody)
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+
+		if err != nil {
+			return redacted
+		}
+
+		d.redactValues(values)
+
+		return values.Encode()
+	default:
+		return string(body)
+	}
+}
+
+// redactValues replaces sensitive fields of a query or form and reports whether there were any.
+func (d *DebugCapture) redactValues(values url.Values) bool {
+	found := false
+
+	for key := range values {
+		if d.isRedactedField(key) {
+			values[key] = []string{redacted}
+			found = true
+		}
+	}
+
+	return found
+}
+
+func (d *DebugCapture) redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if d.isRedactedField(key) {
+				v[key] = redacted
+			} else {
+				v[key] = d.redactValue(field)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = d.redactValue(item)
+		}
+	}
+
+	return value
+}
+
+func (d *DebugCapture) isRedactedField(name string) bool {
+	name = strings.ToLower(name)
+
+	return slices.ContainsFunc(d.redactFields, func(field string) bool {
+		return strings.Contains(name, field)
+	})
+}
+
+// ServeCaptures returns the captured exchanges as JSON.
+func (d *DebugCapture) ServeCaptures(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(d.Exchanges())
+}
+
+// ServeHAR exports the captured exchanges as a HAR file.
+func (d *DebugCapture) ServeHAR(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Disposition", `attachment; filename="captures.har"`)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(NewHAR(d.Exchanges()))
+}
+
+// ServeRules returns the rules on GET and replaces them on PUT.
+func (d *DebugCapture) ServeRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		var rules DebugCaptureRules
+
+		err := json.NewDecoder(r.Body).Decode(&rules)
+
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		d.SetRules(rules)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(d.Rules())
+}
+
+// ServeToken issues a token that is valid for an hour.
+func (d *DebugCapture) ServeToken(w http.ResponseWriter, r *http.Request) {
+	ttl := time.Hour
+	token, err := d.IssueToken(ttl)
+
+	if err != nil {
+		log.Printf("Failed to issue debug capture token: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"token":      token,
+		"header":     DebugCaptureHeader,
+		"expires_at": time.Now().Add(ttl),
+	})
+}
+
+// limitedBuffer keeps the first limit bytes written to it and counts the rest.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	size      int64
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		b.truncated = true
+		b.buf.Write(p[:max(remaining, 0)])
+	} else {
+		b.buf.Write(p)
+	}
+
+	return len(p), nil
+}
+
+// captureWriter copies the response body while it is sent to the client.
+type captureWriter struct {
+	w    http.ResponseWriter
+	body *limitedBuffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+
+	return w.w.Write(b)
+}
+
+// HAR is an HTTP Archive, which browsers' developer tools can import.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// NewHAR converts captured exchanges to a HAR.
+func NewHAR(exchanges []CapturedExchange) HAR {
+	entries := make([]HAREntry, 0, len(exchanges))
+
+	for _, exchange := range exchanges {
+		ms := float64(exchange.Duration) / float64(time.Millisecond)
+
+		request := HARRequest{
+			Method:      exchange.Request.Method,
+			URL:         exchange.Request.URL,
+			HTTPVersion: exchange.Request.Proto,
+			Cookies:     []HARNameValue{},
+			Headers:     harHeaders(exchange.Request.Header),
+			QueryString: []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    exchange.Request.BodySize,
+		}
+
+		if u, err := url.Parse(exchange.Request.URL); err == nil {
+			request.QueryString = harValues(u.Query())
+		}
+
+		if exchange.Request.BodySize > 0 {
+			request.PostData = &HARPostData{
+				MimeType: exchange.Request.Header.Get("Content-Type"),
+				Text:     exchange.Request.Body,
+			}
+		}
+
+		comment := ""
+
+		if exchange.Request.BodyTruncated || exchange.Response.BodyTruncated {
+			comment = "body truncated"
+		}
+
+		entries = append(entries, HAREntry{
+			StartedDateTime: exchange.StartedAt,
+			Time:            ms,
+			Request:         request,
+			Response: HARResponse{
+				Status:      exchange.Response.Status,
+				StatusText:  http.StatusText(exchange.Response.Status),
+				HTTPVersion: exchange.Request.Proto,
+				Cookies:     []HARNameValue{},
+				Headers:     harHeaders(exchange.Response.Header),
+				Content: HARContent{
+					Size:     exchange.Response.BodySize,
+					MimeType: exchange.Response.Header.Get("Content-Type"),
+					Text:     exchange.Response.Body,
+				},
+				RedirectURL: exchange.Response.Header.Get("Location"),
+				HeadersSize: -1,
+				BodySize:    exchange.Response.BodySize,
+			},
+			Timings: HARTimings{Wait: ms},
+			Comment: comment,
+		})
+	}
+
+	return HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{Name: "ready-set-go", Version: "1.0"},
+			Entries: entries,
+		},
+	}
+}
+
+func harHeaders(header http.Header) []HARNameValue {
+	return harValues(url.Values(header))
+}
+
+func harValues(values url.Values) []HARNameValue {
+	pairs := []HARNameValue{}
+
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		for _, value := range values[name] {
+			pairs = append(pairs, HARNameValue{Name: name, Value: value})
+		}
+	}
+
+	return pairs
+}
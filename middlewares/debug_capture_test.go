@@ -0,0 +1,167 @@
+package middlewares_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Set-Cookie", "sessionID=secret")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(body)
+}
+
+func TestDebugCaptureOptIn(t *testing.T) {
+	capture := middlewares.NewDebugCapture(middlewares.DebugCaptureOptions{
+		Subject: func(r *http.Request) string { return r.Header.Get("X-User") },
+	})
+	capture.SetRules(middlewares.DebugCaptureRules{Subjects: []string{"1"}})
+
+	handler := capture.Middleware()(http.HandlerFunc(okHandler))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/whoami", nil))
+	assert.Len(t, capture.Exchanges(), 0)
+
+	req := httptest.NewRequest("GET", "/whoami", nil)
+	req.Header.Set("X-User", "1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	exchanges := capture.Exchanges()
+	assert.Len(t, exchanges, 1)
+	assert.Equal(t, "1", exchanges[0].Subject)
+	assert.Equal(t, http.StatusOK, exchanges[0].Response.Status)
+}
+
+func TestDebugCaptureToken(t *testing.T) {
+	capture := middlewares.NewDebugCapture(middlewares.DebugCaptureOptions{})
+	handler := capture.Middleware()(http.HandlerFunc(okHandler))
+
+	token, err := capture.IssueToken(time.Minute)
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(middlewares.DebugCaptureHeader, "wrong")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Len(t, capture.Exchanges(), 0)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(middlewares.DebugCaptureHeader, token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	exchanges := capture.Exchanges()
+	assert.Len(t, exchanges, 1)
+	assert.Equal(t, "[REDACTED]", exchanges[0].Request.Header.Get(middlewares.DebugCaptureHeader))
+}
+
+func TestDebugCaptureRedacts(t *testing.T) {
+	capture := middlewares.NewDebugCapture(middlewares.DebugCaptureOptions{})
+	capture.SetRules(middlewares.DebugCaptureRules{Routes: []string{"POST /register"}})
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /register", capture.Middleware()(http.HandlerFunc(echoHandler)))
+
+	req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"username": "bob", "password": "hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.AddCookie(&http.Cookie{Name: "sessionID", Value: "secret"})
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+
+	assert.Equal(t, `{"username": "bob", "password": "hunter2"}`, recorder.Body.String())
+
+	exchanges := capture.Exchanges()
+	assert.Len(t, exchanges, 1)
+
+	exchange := exchanges[0]
+	assert.Equal(t, "POST /register", exchange.Route)
+	assert.Equal(t, `{"password":"[REDACTED]","username":"bob"}`, exchange.Request.Body)
+	assert.Equal(t, `{"password":"[REDACTED]","username":"bob"}`, exchange.Response.Body)
+	assert.Equal(t, "[REDACTED]", exchange.Request.Header.Get("Authorization"))
+	assert.Equal(t, "[REDACTED]", exchange.Request.Header.Get("Cookie"))
+	assert.Equal(t, "[REDACTED]", exchange.Response.Header.Get("Set-Cookie"))
+	assert.NotContains(t, exchange.Request.Body+exchange.Response.Body, "hunter2")
+}
+
+func TestDebugCaptureRedactsQuery(t *testing.T) {
+	capture := middlewares.NewDebugCapture(middlewares.DebugCaptureOptions{RedactFields: []string{"api_key"}})
+	capture.SetRules(middlewares.DebugCaptureRules{Routes: []string{"GET /items"}})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /items", capture.Middleware()(http.HandlerFunc(okHandler)))
+
+	for _, target := range []string{"/items?access_token=abc&page=2&api_key=def", "/items?page=2", "/items?token=abc;x"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	exchanges := capture.Exchanges()
+	assert.Len(t, exchanges, 3)
+
+	assert.Equal(t, "http://example.com/items?access_token=%5BREDACTED%5D&api_key=%5BREDACTED%5D&page=2", exchanges[0].Request.URL)
+	assert.Equal(t, "http://example.com/items?page=2", exchanges[1].Request.URL)
+	assert.Equal(t, "http://example.com/items?[REDACTED]", exchanges[2].Request.URL)
+
+	har := middlewares.NewHAR(exchanges)
+	assert.Contains(t, har.Log.Entries[0].Request.QueryString, middlewares.HARNameValue{Name: "access_token", Value: "[REDACTED]"})
+	assert.Contains(t, har.Log.Entries[0].Request.QueryString, middlewares.HARNameValue{Name: "page", Value: "2"})
+}
+
+func TestDebugCaptureRingBuffer(t *testing.T) {
+	capture := middlewares.NewDebugCapture(middlewares.DebugCaptureOptions{Capacity: 2, MaxBodySize: 4})
+	capture.SetRules(middlewares.DebugCaptureRules{Routes: []string{"GET /"}})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /", capture.Middleware()(textHandler("hello world")))
+
+	for i := range 3 {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", fmt.Sprintf("/%d", i), nil))
+	}
+
+	exchanges := capture.Exchanges()
+	assert.Len(t, exchanges, 2)
+	assert.Equal(t, "http://example.com/1", exchanges[0].Request.URL)
+	assert.Equal(t, "http://example.com/2", exchanges[1].Request.URL)
+	assert.True(t, exchanges[1].Response.BodyTruncated)
+	assert.Len(t, exchanges[1].Response.Body, 4)
+}
+
+func TestDebugCaptureHAR(t *testing.T) {
+	capture := middlewares.NewDebugCapture(middlewares.DebugCaptureOptions{})
+	capture.SetRules(middlewares.DebugCaptureRules{Routes: []string{"POST /register"}})
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /register", capture.Middleware()(http.HandlerFunc(echoHandler)))
+
+	req := httptest.NewRequest("POST", "/register?source=app", strings.NewReader(`{"username": "bob"}`))
+	req.Header.Set("Content-Type", "application/json")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	recorder := httptest.NewRecorder()
+	capture.ServeHAR(recorder, httptest.NewRequest("GET", "/admin/debug/captures.har", nil))
+
+	var har middlewares.HAR
+
+	err := json.NewDecoder(recorder.Body).Decode(&har)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Len(t, har.Log.Entries, 1)
+
+	entry := har.Log.Entries[0]
+	assert.Equal(t, "POST", entry.Request.Method)
+	assert.Equal(t, []middlewares.HARNameValue{{Name: "source", Value: "app"}}, entry.Request.QueryString)
+	assert.Equal(t, `{"username":"bob"}`, entry.Request.PostData.Text)
+	assert.Equal(t, http.StatusCreated, entry.Response.Status)
+	assert.Equal(t, "Created", entry.Response.StatusText)
+}
@@ -0,0 +1,192 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// ETagOptions configures the [ETag] middleware.
+type ETagOptions struct {
+	// Weak generates weak ETags, for responses that are equivalent but not byte for byte identical.
+	Weak bool
+	// MaxSize is the largest response that is buffered. Larger responses are sent without an ETag.
+	// Defaults to 1 MiB.
+	MaxSize int
+}
+
+// ETag buffers successful GET and HEAD responses to add an ETag and answers
+// conditional requests with `If-None-Match` or `If-Modified-Since` with a 304.
+// ETags set by handlers are kept. Flushed responses are not buffered.
+func ETag(options ETagOptions) router.Middleware {
+	if options.MaxSize == 0 {
+		options.MaxSize = 1 << 20
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ew := &etagWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+				maxSize:        options.MaxSize,
+			}
+
+			next.ServeHTTP(router.WrapResponseWriterWithHooks(w, router.Hooks{
+				WriteHeader: ew.WriteHeader,
+				Write:       ew.Write,
+				Flush:       ew.Flush,
+			}), r)
+
+			if ew.passthrough {
+				return
+			}
+
+			headers := w.Header()
+
+			if ew.statusCode != http.StatusOK {
+				w.WriteHeader(ew.statusCode)
+				w.Write(ew.buf.Bytes())
+				return
+			}
+
+			etag := headers.Get("ETag")
+
+			if etag == "" {
+				sum := sha256.Sum256(ew.buf.Bytes())
+				etag = `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+
+				if options.Weak {
+					etag = "W/" + etag
+				}
+
+				headers.Set("ETag", etag)
+			}
+
+			if isNotModified(r, headers) {
+				headers.Del("Content-Type")
+				headers.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+			w.Write(ew.buf.Bytes())
+		})
+	}
+}
+
+// isNotModified evaluates the conditional headers of a request as described in RFC 9110.
+func isNotModified(r *http.Request, headers http.Header) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := strings.TrimPrefix(headers.Get("ETag"), "W/")
+
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+
+	if err != nil {
+		return false
+	}
+
+	lastModified, err := http.ParseTime(headers.Get("Last-Modified"))
+
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(ifModifiedSince)
+}
+
+type etagWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	maxSize     int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if !w.wroteHeader {
+		w.statusCode = code
+		w.wroteHeader = true
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+
+	if w.buf.Len()+len(b) > w.maxSize {
+		err := w.startPassthrough()
+
+		if err != nil {
+			return 0, err
+		}
+
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.buf.Write(b)
+}
+
+// Flush sends the buffered response without an ETag.
+func (w *etagWriter) Flush() {
+	if !w.passthrough {
+		w.startPassthrough()
+	}
+
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *etagWriter) startPassthrough() error {
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf = bytes.Buffer{}
+
+	return err
+}
+
+// CacheControl sets the `Cache-Control` header of a route, e.g. `private, no-cache`.
+func CacheControl(policy string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", policy)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETag(t *testing.T) {
+	handler := middlewares.ETag(middlewares.ETagOptions{})(textHandler("hello"))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	etag := recorder.Header().Get("ETag")
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "hello", recorder.Body.String())
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", "W/"+etag)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	assert.Equal(t, "", recorder.Body.String())
+}
+
+func TestETagIfModifiedSince(t *testing.T) {
+	handler := middlewares.ETag(middlewares.ETagOptions{Weak: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "Wed, 01 Jan 2025 00:00:00 GMT")
+		w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-Modified-Since", "Thu, 02 Jan 2025 00:00:00 GMT")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("ETag"), "W/")
+}
+
+func TestETagSkipsErrors(t *testing.T) {
+	handler := middlewares.ETag(middlewares.ETagOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "", recorder.Header().Get("ETag"))
+	assert.Equal(t, "not found", recorder.Body.String())
+}
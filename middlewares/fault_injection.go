@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"slices"
+	"sync"
+)
+
+// Fault describes a failure that is injected into matching requests.
+type Fault struct {
+	// Routes are matched against the route pattern, e.g. `POST /login`.
+	// A fault without routes matches every route.
+	Routes []string `json:"routes"`
+	// Probability is the chance, between 0 and 1, that a request gets the fault.
+	Probability float64 `json:"probability"`
+	// LatencyMS delays the request.
+	LatencyMS int `json:"latency_ms"`
+	// Status is sent instead of calling the handler.
+	Status int `json:"status"`
+	// Abort closes the connection without a response.
+	Abort bool `json:"abort"`
+	// BodyBytesPerSecond throttles the response body.
+	BodyBytesPerSecond int `json:"body_bytes_per_second"`
+}
+
+// FaultInjector injects latency, errors, aborted connections and slow bodies,
+// so clients' retry logic can be tested. It does nothing unless the app is
+// built with the `faultinject` tag.
+type FaultInjector struct {
+	mu     sync.Mutex
+	faults []Fault
+}
+
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{}
+}
+
+func (f *FaultInjector) Faults() []Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.faults)
+}
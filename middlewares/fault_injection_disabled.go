@@ -0,0 +1,27 @@
+//go:build !faultinject
+
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// ErrFaultInjectionDisabled is returned when faults are set in a build without the `faultinject` tag.
+var ErrFaultInjectionDisabled = errors.New("Fault injection is disabled, build with -tags faultinject to enable it")
+
+func (f *FaultInjector) Set(faults []Fault) error {
+	return ErrFaultInjectionDisabled
+}
+
+func (f *FaultInjector) Middleware() router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return next
+	}
+}
+
+func (f *FaultInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.NotFound(w, r)
+}
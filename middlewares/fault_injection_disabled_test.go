@@ -0,0 +1,27 @@
+//go:build !faultinject
+
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultInjectionDisabled(t *testing.T) {
+	injector := middlewares.NewFaultInjector()
+
+	err := injector.Set([]middlewares.Fault{{Probability: 1, Status: http.StatusServiceUnavailable}})
+	assert.ErrorIs(t, err, middlewares.ErrFaultInjectionDisabled)
+
+	recorder := httptest.NewRecorder()
+	injector.Middleware()(textHandler("hello")).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	injector.ServeHTTP(recorder, httptest.NewRequest("GET", "/admin/faults", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
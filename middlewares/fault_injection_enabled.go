@@ -0,0 +1,159 @@
+//go:build faultinject
+
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// Set replaces the faults. The first matching fault whose probability hits is injected.
+func (f *FaultInjector) Set(faults []Fault) error {
+	for _, fault := range faults {
+		err := fault.validate()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = faults
+
+	return nil
+}
+
+func (f Fault) validate() error {
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("Invalid probability %v, it has to be between 0 and 1", f.Probability)
+	}
+
+	if f.LatencyMS < 0 || f.BodyBytesPerSecond < 0 {
+		return errors.New("Latency and body bytes per second can't be negative")
+	}
+
+	if f.Status != 0 && (f.Status < 400 || f.Status > 599) {
+		return fmt.Errorf("Invalid status %d, it has to be an error status", f.Status)
+	}
+
+	return nil
+}
+
+func (f Fault) matches(r *http.Request) bool {
+	return len(f.Routes) == 0 || slices.Contains(f.Routes, r.Pattern)
+}
+
+func (f *FaultInjector) pick(r *http.Request) (Fault, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, fault := range f.faults {
+		if fault.matches(r) && rand.Float64() < fault.Probability {
+			return fault, true
+		}
+	}
+
+	return Fault{}, false
+}
+
+func (f *FaultInjector) Middleware() router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fault, ok := f.pick(r)
+
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if fault.LatencyMS > 0 {
+				select {
+				case <-time.After(time.Duration(fault.LatencyMS) * time.Millisecond):
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+			if fault.Abort {
+				panic(http.ErrAbortHandler)
+			}
+
+			if fault.Status != 0 {
+				http.Error(w, http.StatusText(fault.Status), fault.Status)
+				return
+			}
+
+			if fault.BodyBytesPerSecond > 0 {
+				sw := &slowWriter{w: w, r: r, bytesPerSecond: fault.BodyBytesPerSecond}
+				w = router.WrapResponseWriterWithHooks(w, router.Hooks{Write: sw.Write})
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ServeHTTP returns the faults on GET and replaces them on PUT.
+func (f *FaultInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		var faults []Fault
+
+		err := json.NewDecoder(r.Body).Decode(&faults)
+
+		if err == nil {
+			err = f.Set(faults)
+		}
+
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(f.Faults())
+}
+
+// slowWriter sends the body in small flushed chunks, ten per second.
+type slowWriter struct {
+	w              http.ResponseWriter
+	r              *http.Request
+	bytesPerSecond int
+}
+
+func (w *slowWriter) Write(b []byte) (int, error) {
+	chunkSize := max(w.bytesPerSecond/10, 1)
+	written := 0
+
+	for written < len(b) {
+		end := min(written+chunkSize, len(b))
+		n, err := w.w.Write(b[written:end])
+		written += n
+
+		if err != nil {
+			return written, err
+		}
+
+		http.NewResponseController(w.w).Flush()
+
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-w.r.Context().Done():
+			return written, w.r.Context().Err()
+		}
+	}
+
+	return written, nil
+}
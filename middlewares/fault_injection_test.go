@@ -0,0 +1,110 @@
+//go:build faultinject
+
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func faultMux(injector *middlewares.FaultInjector) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("GET /", injector.Middleware()(textHandler("hello world")))
+	mux.Handle("POST /login", injector.Middleware()(textHandler("hello world")))
+
+	return mux
+}
+
+func TestFaultInjectionStatus(t *testing.T) {
+	injector := middlewares.NewFaultInjector()
+	err := injector.Set([]middlewares.Fault{{Routes: []string{"POST /login"}, Probability: 1, Status: http.StatusServiceUnavailable}})
+	assert.Nil(t, err)
+
+	mux := faultMux(injector)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/login", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "hello world", recorder.Body.String())
+}
+
+func TestFaultInjectionProbability(t *testing.T) {
+	injector := middlewares.NewFaultInjector()
+	err := injector.Set([]middlewares.Fault{{Probability: 0, Status: http.StatusInternalServerError}})
+	assert.Nil(t, err)
+
+	mux := faultMux(injector)
+
+	for range 100 {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+}
+
+func TestFaultInjectionLatency(t *testing.T) {
+	injector := middlewares.NewFaultInjector()
+	err := injector.Set([]middlewares.Fault{{Probability: 1, LatencyMS: 50}})
+	assert.Nil(t, err)
+
+	start := time.Now()
+	recorder := httptest.NewRecorder()
+	faultMux(injector).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestFaultInjectionAbort(t *testing.T) {
+	injector := middlewares.NewFaultInjector()
+	err := injector.Set([]middlewares.Fault{{Probability: 1, Abort: true}})
+	assert.Nil(t, err)
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		faultMux(injector).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+}
+
+func TestFaultInjectionSlowBody(t *testing.T) {
+	injector := middlewares.NewFaultInjector()
+	err := injector.Set([]middlewares.Fault{{Probability: 1, BodyBytesPerSecond: 50}})
+	assert.Nil(t, err)
+
+	start := time.Now()
+	recorder := httptest.NewRecorder()
+	faultMux(injector).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, "hello world", recorder.Body.String())
+	assert.True(t, recorder.Flushed)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+}
+
+func TestFaultInjectionAdminEndpoint(t *testing.T) {
+	injector := middlewares.NewFaultInjector()
+
+	recorder := httptest.NewRecorder()
+	injector.ServeHTTP(recorder, httptest.NewRequest("PUT", "/admin/faults", strings.NewReader(`[{"probability": 0.5, "status": 502}]`)))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, []middlewares.Fault{{Probability: 0.5, Status: http.StatusBadGateway}}, injector.Faults())
+
+	for _, body := range []string{`[{"probability": 2}]`, `[{"probability": 1, "status": 200}]`} {
+		recorder = httptest.NewRecorder()
+		injector.ServeHTTP(recorder, httptest.NewRequest("PUT", "/admin/faults", strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	}
+}
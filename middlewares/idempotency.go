@@ -0,0 +1,311 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// IdempotentResponse is a stored response that is replayed for retries.
+type IdempotentResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// IdempotencyRecord is what an [IdempotencyStore] keeps for a key.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request body the key was first used with.
+	Fingerprint string
+	// Response is nil while the first request is in flight.
+	Response *IdempotentResponse
+}
+
+type IdempotencyStore interface {
+	// Begin reserves key for a request until lockTimeout passes.
+	// If the key is already in use, its record is returned and reserved is false.
+	Begin(ctx context.Context, key string, fingerprint string, lockTimeout time.Duration) (record IdempotencyRecord, reserved bool, err error)
+	// Complete stores the response for key until ttl passes.
+	Complete(ctx context.Context, key string, response IdempotentResponse, ttl time.Duration) error
+	// Release removes a reservation, so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyOptions configures the [Idempotency] middleware.
+type IdempotencyOptions struct {
+	Store IdempotencyStore
+	// TTL is how long responses are replayed. Defaults to 24 hours.
+	TTL time.Duration
+	// LockTimeout is how long a request keeps its key before it is considered lost. Defaults to 1 minute.
+	LockTimeout time.Duration
+	// Scope separates the keys of different clients, e.g. by returning the user ID.
+	// Without it, all clients share their keys.
+	Scope func(r *http.Request) string
+}
+
+// Idempotency replays the first response for requests with the same `Idempotency-Key` header.
+//
+// Keys are scoped by user and route. A retry while the first request is in flight
+// gets a 409 and reusing a key with a different body gets a 422.
+// Server errors are not stored, so those requests can be retried.
+// Only headers set by the handler are stored, without `Set-Cookie` and the
+// headers that describe the encoding of the body, which middlewares set again on replay.
+func Idempotency(options IdempotencyOptions) router.Middleware {
+	if options.TTL == 0 {
+		options.TTL = 24 * time.Hour
+	}
+
+	if options.LockTimeout == 0 {
+		options.LockTimeout = time.Minute
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idempotencyKey := r.Header.Get("Idempotency-Key")
+
+			if idempotencyKey == "" || isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(idempotencyKey) > 255 {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+
+			if err != nil {
+				writeReadBodyError(w, err)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			scope := ""
+
+			if options.Scope != nil {
+				scope = options.Scope(r)
+			}
+
+			key := scope + ":" + r.Method + " " + r.Pattern + ":" + idempotencyKey
+			fingerprint := sha256.Sum256(body)
+			ctx := r.Context()
+
+			record, reserved, err := options.Store.Begin(ctx, key, hex.EncodeToString(fingerprint[:]), options.LockTimeout)
+
+			if err != nil {
+				log.Printf("Idempotency store failed: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !reserved {
+				replayIdempotentResponse(w, record, hex.EncodeToString(fingerprint[:]))
+				return
+			}
+
+			recorder := &idempotencyRecorder{w: w, before: w.Header().Clone()}
+			rw := router.WrapResponseWriterWithHooks(w, router.Hooks{
+				WriteHeader: recorder.WriteHeader,
+				Write:       recorder.Write,
+			})
+
+			defer func() {
+				// Background context, so the result is stored even if the client went away.
+				ctx := context.WithoutCancel(ctx)
+				p := recover()
+
+				statusCode := rw.Status()
+
+				if statusCode == 0 {
+					statusCode = http.StatusOK
+				}
+
+				if statusCode >= 500 || p != nil {
+					err = options.Store.Release(ctx, key)
+				} else {
+					err = options.Store.Complete(ctx, key, IdempotentResponse{
+						StatusCode: statusCode,
+						Header:     recorder.handlerHeader(),
+						Body:       recorder.body.Bytes(),
+					}, options.TTL)
+				}
+
+				if err != nil {
+					log.Printf("Idempotency store failed: %v", err)
+				}
+
+				if p != nil {
+					panic(p)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, record IdempotencyRecord, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		http.Error(w, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if record.Response == nil {
+		http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	}
+
+	headers := w.Header()
+
+	for key, values := range record.Response.Header {
+		headers[key] = values
+	}
+
+	headers.Set("Idempotent-Replayed", "true")
+	headers.Set("Content-Length", strconv.Itoa(len(record.Response.Body)))
+	w.WriteHeader(record.Response.StatusCode)
+	w.Write(record.Response.Body)
+}
+
+// unreplayedHeaders describe the body as it was sent the first time, or belong to one response.
+var unreplayedHeaders = []string{
+	"Set-Cookie",
+	"Content-Encoding",
+	"Content-Length",
+	"ETag",
+	"Vary",
+}
+
+// idempotencyRecorder copies the response body while it is sent to the client.
+type idempotencyRecorder struct {
+	w    http.ResponseWriter
+	body bytes.Buffer
+	// before is the header before the handler ran, header what the handler added to it.
+	before http.Header
+	header http.Header
+}
+
+func (w *idempotencyRecorder) WriteHeader(code int) {
+	if code >= 200 {
+		w.recordHeader()
+	}
+
+	w.w.WriteHeader(code)
+}
+
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	w.recordHeader()
+	w.body.Write(b)
+
+	return w.w.Write(b)
+}
+
+// recordHeader keeps the headers the handler set. It has to run before the response
+// starts, because writers further down like [Compress] change the header when it does.
+func (w *idempotencyRecorder) recordHeader() {
+	if w.header != nil {
+		return
+	}
+
+	w.header = http.Header{}
+
+	for key, values := range w.w.Header() {
+		if !slices.Equal(w.before[key], values) {
+			w.header[key] = slices.Clone(values)
+		}
+	}
+
+	for _, key := range unreplayedHeaders {
+		w.header.Del(key)
+	}
+}
+
+func (w *idempotencyRecorder) handlerHeader() http.Header {
+	w.recordHeader()
+
+	return w.header
+}
+
+type memoryIdempotencyEntry struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+// MemoryIdempotencyStore keeps idempotency keys in memory. Keys are not shared between instances.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryIdempotencyEntry
+	lastSweep time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]memoryIdempotencyEntry),
+	}
+}
+
+// Begin implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Begin(ctx context.Context, key string, fingerprint string, lockTimeout time.Duration) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		for key, entry := range s.entries {
+			if now.After(entry.expiresAt) {
+				delete(s.entries, key)
+			}
+		}
+
+		s.lastSweep = now
+	}
+
+	entry, found := s.entries[key]
+
+	if found && now.Before(entry.expiresAt) {
+		return entry.record, false, nil
+	}
+
+	s.entries[key] = memoryIdempotencyEntry{
+		record:    IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: now.Add(lockTimeout),
+	}
+
+	return IdempotencyRecord{}, true, nil
+}
+
+// Complete implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, response IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[key]
+	entry.record.Response = &response
+	entry.expiresAt = time.Now().Add(ttl)
+	s.entries[key] = entry
+
+	return nil
+}
+
+// Release implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package middlewares_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	calls := 0
+
+	handler := middlewares.Idempotency(middlewares.IdempotencyOptions{
+		Store: middlewares.NewMemoryIdempotencyStore(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 1}`))
+	}))
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest("POST", "/register", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "key1")
+
+		return req
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest(`{"username": "user1"}`))
+
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest(`{"username": "user1"}`))
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, `{"id": 1}`, recorder.Body.String())
+	assert.Equal(t, "true", recorder.Header().Get("Idempotent-Replayed"))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest(`{"username": "user2"}`))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+}
+
+func TestIdempotencyReplayThroughCompress(t *testing.T) {
+	body := `{"items": "` + strings.Repeat("a", 2000) + `"}`
+
+	handler := middlewares.Compress(middlewares.CompressOptions{})(
+		middlewares.Idempotency(middlewares.IdempotencyOptions{
+			Store: middlewares.NewMemoryIdempotencyStore(),
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Location", "/items/1")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(body))
+		})),
+	)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("POST", "/items", nil)
+		req.Header.Set("Idempotency-Key", "key1")
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		return req
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest())
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest())
+
+	assert.Equal(t, "true", recorder.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "/items/1", recorder.Header().Get("Location"))
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+	assert.Equal(t, []string{"Accept-Encoding"}, recorder.Header().Values("Vary"))
+
+	reader, err := gzip.NewReader(recorder.Body)
+	assert.Nil(t, err)
+
+	replayed, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, body, string(replayed))
+}
+
+func TestIdempotencyRejectsInFlightDuplicates(t *testing.T) {
+	store := middlewares.NewMemoryIdempotencyStore()
+	var inner *httptest.ResponseRecorder
+
+	var handler http.Handler
+
+	handler = middlewares.Idempotency(middlewares.IdempotencyOptions{
+		Store: store,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inner != nil {
+			return
+		}
+
+		// Send the retry while the first request is still running.
+		inner = httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/register", nil)
+		req.Header.Set("Idempotency-Key", "key1")
+		handler.ServeHTTP(inner, req)
+	}))
+
+	req := httptest.NewRequest("POST", "/register", nil)
+	req.Header.Set("Idempotency-Key", "key1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, http.StatusConflict, inner.Code)
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	calls := 0
+
+	handler := middlewares.Idempotency(middlewares.IdempotencyOptions{
+		Store: middlewares.NewMemoryIdempotencyStore(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	for range 2 {
+		req := httptest.NewRequest("POST", "/register", nil)
+		req.Header.Set("Idempotency-Key", "key1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyRejectsLargeBodies(t *testing.T) {
+	handler := middlewares.MaxBodySize(4)(middlewares.Idempotency(middlewares.IdempotencyOptions{
+		Store: middlewares.NewMemoryIdempotencyStore(),
+	})(http.HandlerFunc(okHandler)))
+
+	// Without a Content-Length, the size is only known while the body is read.
+	req := httptest.NewRequest("POST", "/register", io.MultiReader(strings.NewReader(`{"username": "user1"}`)))
+	req.ContentLength = -1
+	req.Header.Set("Idempotency-Key", "key1")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+}
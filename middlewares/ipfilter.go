@@ -0,0 +1,169 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// ipFilterDecisions counts the decisions of every [IPFilter] by `<name>.allowed` and `<name>.denied`.
+var ipFilterDecisions = expvar.NewMap("ip_filter_decisions")
+
+// IPAccessList decides which client IPs can access a group of routes.
+// It can be changed while the app is running.
+type IPAccessList struct {
+	mu    sync.RWMutex
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// ipAccessListFile is the format of the file read by [IPAccessList.LoadFile].
+type ipAccessListFile struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+func NewIPAccessList(allow []netip.Prefix, deny []netip.Prefix) *IPAccessList {
+	return &IPAccessList{
+		allow: allow,
+		deny:  deny,
+	}
+}
+
+func (l *IPAccessList) Set(allow []netip.Prefix, deny []netip.Prefix) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.allow = allow
+	l.deny = deny
+}
+
+// Allowed reports whether addr can access the routes.
+// Denied networks win over allowed ones. An empty allow list allows every address that isn't denied.
+func (l *IPAccessList) Allowed(addr netip.Addr) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if !addr.IsValid() {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, prefix := range l.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	if len(l.allow) == 0 {
+		return true
+	}
+
+	for _, prefix := range l.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadFile replaces the lists with the ones in a JSON file, e.g.
+// `{"allow": ["10.8.0.0/16", "2001:db8::/32"], "deny": ["10.8.0.13"]}`.
+func (l *IPAccessList) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read IP access list: %w", err)
+	}
+
+	var file ipAccessListFile
+
+	err = json.Unmarshal(data, &file)
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse IP access list: %w", err)
+	}
+
+	allow, err := ParsePrefixes(file.Allow)
+
+	if err != nil {
+		return err
+	}
+
+	deny, err := ParsePrefixes(file.Deny)
+
+	if err != nil {
+		return err
+	}
+
+	l.Set(allow, deny)
+
+	return nil
+}
+
+// WatchFile reloads the file whenever it changes, until ctx is done.
+func (l *IPAccessList) WatchFile(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastModified time.Time
+
+	if info, err := os.Stat(path); err == nil {
+		lastModified = info.ModTime()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+
+			if err != nil || !info.ModTime().After(lastModified) {
+				continue
+			}
+
+			lastModified = info.ModTime()
+			err = l.LoadFile(path)
+
+			if err != nil {
+				log.Printf("Failed to reload IP access list %s: %v", path, err)
+				continue
+			}
+
+			log.Printf("Reloaded IP access list %s", path)
+		}
+	}
+}
+
+// IPFilter rejects requests from clients that are not allowed by the list with a 403.
+// The client IP is resolved by [RealIP]. Decisions are counted in the
+// `ip_filter_decisions` expvar and denied requests are logged.
+func IPFilter(name string, list *IPAccessList) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientIP := ClientIP(r)
+
+			if !list.Allowed(clientIP) {
+				ipFilterDecisions.Add(name+".denied", 1)
+				log.Printf("IP filter %s denied %v %s %s", name, clientIP, r.Method, r.URL.Path)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			ipFilterDecisions.Add(name+".allowed", 1)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
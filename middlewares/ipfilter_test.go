@@ -0,0 +1,56 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPAccessList(t *testing.T) {
+	allow, err := middlewares.ParsePrefixes([]string{"10.8.0.0/16", "2001:db8::/32"})
+	assert.Nil(t, err)
+
+	deny, err := middlewares.ParsePrefixes([]string{"10.8.0.13"})
+	assert.Nil(t, err)
+
+	list := middlewares.NewIPAccessList(allow, deny)
+
+	assert.True(t, list.Allowed(netip.MustParseAddr("10.8.1.1")))
+	assert.True(t, list.Allowed(netip.MustParseAddr("::ffff:10.8.1.1")))
+	assert.True(t, list.Allowed(netip.MustParseAddr("2001:db8::1")))
+	assert.False(t, list.Allowed(netip.MustParseAddr("10.8.0.13")))
+	assert.False(t, list.Allowed(netip.MustParseAddr("192.0.2.1")))
+}
+
+func TestIPAccessListLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.json")
+	err := os.WriteFile(path, []byte(`{"allow": ["192.0.2.0/24"]}`), 0o600)
+	assert.Nil(t, err)
+
+	list := middlewares.NewIPAccessList(nil, nil)
+	assert.Nil(t, list.LoadFile(path))
+
+	assert.True(t, list.Allowed(netip.MustParseAddr("192.0.2.1")))
+	assert.False(t, list.Allowed(netip.MustParseAddr("198.51.100.1")))
+}
+
+func TestIPFilter(t *testing.T) {
+	allow, err := middlewares.ParsePrefixes([]string{"192.0.2.0/24"})
+	assert.Nil(t, err)
+
+	handler := middlewares.IPFilter("test", middlewares.NewIPAccessList(allow, nil))(http.HandlerFunc(okHandler))
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
@@ -0,0 +1,218 @@
+package middlewares
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// MaxBodySize rejects requests with a body larger than n bytes with a 413.
+// Bodies without a Content-Length fail while they are read with an [http.MaxBytesError].
+func MaxBodySize(n int64) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeReadBodyError responds to a body that couldn't be read, with a 413 if it
+// was larger than [MaxBodySize] allows and a 400 otherwise.
+func writeReadBodyError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	http.Error(w, "Failed to read body", http.StatusBadRequest)
+}
+
+// TimeoutOptions configures the [Timeout] middleware.
+type TimeoutOptions struct {
+	Timeout time.Duration
+	// StatusCode is sent when the handler times out. Defaults to 503.
+	StatusCode int
+}
+
+// Timeout sets a deadline on the request context.
+//
+// If the handler hasn't started its response when the deadline passes, the
+// client gets a StatusCode response and later writes of the handler fail with
+// [http.ErrHandlerTimeout]. A response that was already started is never mixed
+// with the timeout response, the handler has to stop on its own by watching the context.
+func Timeout(options TimeoutOptions) router.Middleware {
+	if options.StatusCode == 0 {
+		options.StatusCode = http.StatusServiceUnavailable
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), options.Timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{
+				w:      w,
+				ctx:    ctx,
+				header: w.Header().Clone(),
+			}
+
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+
+				next.ServeHTTP(router.WrapResponseWriterWithHooks(w, router.Hooks{
+					Header:      tw.Header,
+					WriteHeader: tw.WriteHeader,
+					Write:       tw.Write,
+					Flush:       tw.Flush,
+					Hijack:      tw.Hijack,
+				}), r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				panic(p)
+			case <-done:
+			case <-ctx.Done():
+			}
+
+			tw.mu.Lock()
+
+			if !tw.wroteHeader && ctx.Err() == context.DeadlineExceeded {
+				tw.timedOut = true
+				tw.mu.Unlock()
+				http.Error(w, http.StatusText(options.StatusCode), options.StatusCode)
+				return
+			}
+
+			tw.mu.Unlock()
+
+			select {
+			case p := <-panicChan:
+				panic(p)
+			case <-done:
+			}
+		})
+	}
+}
+
+// timeoutWriter guards the response, so the handler and the timeout response
+// are never written at the same time.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	ctx    context.Context
+	header http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.writeHeader(code)
+}
+
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+
+	// The timeout response wins over a response started after the deadline.
+	if tw.ctx.Err() == context.DeadlineExceeded {
+		tw.timedOut = true
+		return
+	}
+
+	dst := tw.w.Header()
+
+	for key, values := range tw.header {
+		dst[key] = values
+	}
+
+	tw.w.WriteHeader(code)
+
+	if code >= 200 {
+		tw.wroteHeader = true
+	}
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	tw.writeHeader(http.StatusOK)
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	return tw.w.Write(b)
+}
+
+// Hijack hands the connection to the handler, unless the timeout response was sent.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if !tw.wroteHeader && tw.ctx.Err() == context.DeadlineExceeded {
+		tw.timedOut = true
+	}
+
+	if tw.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+
+	conn, buf, err := http.NewResponseController(tw.w).Hijack()
+
+	if err == nil {
+		tw.wroteHeader = true
+	}
+
+	return conn, buf, err
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.writeHeader(http.StatusOK)
+
+	if !tw.timedOut {
+		http.NewResponseController(tw.w).Flush()
+	}
+}
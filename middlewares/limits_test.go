@@ -0,0 +1,69 @@
+package middlewares_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	handler := middlewares.MaxBodySize(4)(http.HandlerFunc(okHandler))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/", strings.NewReader("too large")))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+}
+
+func TestMaxBodySizeWithoutContentLength(t *testing.T) {
+	var readErr error
+
+	handler := middlewares.MaxBodySize(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	req := httptest.NewRequest("POST", "/", io.NopCloser(strings.NewReader("too large")))
+	req.ContentLength = -1
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var maxBytesErr *http.MaxBytesError
+	assert.ErrorAs(t, readErr, &maxBytesErr)
+}
+
+func TestTimeout(t *testing.T) {
+	handler := middlewares.Timeout(middlewares.TimeoutOptions{
+		Timeout:    10 * time.Millisecond,
+		StatusCode: http.StatusGatewayTimeout,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Write([]byte("late"))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "late")
+}
+
+func TestTimeoutKeepsStartedResponse(t *testing.T) {
+	handler := middlewares.Timeout(middlewares.TimeoutOptions{
+		Timeout: 10 * time.Millisecond,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("started"))
+		<-r.Context().Done()
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "started", recorder.Body.String())
+}
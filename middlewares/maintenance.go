@@ -0,0 +1,138 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+type MaintenanceMode string
+
+const (
+	MaintenanceOff MaintenanceMode = "off"
+	// MaintenanceReadOnly rejects requests with unsafe methods.
+	MaintenanceReadOnly MaintenanceMode = "read-only"
+	// MaintenanceFull rejects all requests.
+	MaintenanceFull MaintenanceMode = "full"
+)
+
+// MaintenanceState is the JSON representation used by the admin endpoint and the config file.
+type MaintenanceState struct {
+	Mode MaintenanceMode `json:"mode"`
+	// RetryAfter is sent to clients as the `Retry-After` header, in seconds.
+	RetryAfter int    `json:"retry_after"`
+	Message    string `json:"message"`
+}
+
+// Maintenance holds the maintenance mode of the app, which can be changed at runtime.
+type Maintenance struct {
+	mu    sync.RWMutex
+	state MaintenanceState
+}
+
+func NewMaintenance() *Maintenance {
+	return &Maintenance{
+		state: MaintenanceState{Mode: MaintenanceOff},
+	}
+}
+
+func (m *Maintenance) State() MaintenanceState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.state
+}
+
+func (m *Maintenance) Set(state MaintenanceState) error {
+	switch state.Mode {
+	case MaintenanceOff, MaintenanceReadOnly, MaintenanceFull:
+	default:
+		return fmt.Errorf("Invalid maintenance mode: %s", state.Mode)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.state = state
+
+	return nil
+}
+
+// LoadFile sets the state from a JSON file. A missing file turns maintenance off.
+func (m *Maintenance) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return m.Set(MaintenanceState{Mode: MaintenanceOff})
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to read maintenance file: %w", err)
+	}
+
+	var state MaintenanceState
+
+	err = json.Unmarshal(data, &state)
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse maintenance file: %w", err)
+	}
+
+	return m.Set(state)
+}
+
+// Middleware rejects requests affected by the maintenance mode with a 503.
+// Use it on the groups that should go down, not on health or admin routes.
+func (m *Maintenance) Middleware() router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state := m.State()
+
+			if state.Mode == MaintenanceFull || (state.Mode == MaintenanceReadOnly && !isSafeMethod(r.Method)) {
+				if state.RetryAfter > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(state.RetryAfter))
+				}
+
+				message := state.Message
+
+				if message == "" {
+					message = "Down for maintenance"
+				}
+
+				http.Error(w, message, http.StatusServiceUnavailable)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ServeHTTP returns the state on GET and changes it on PUT.
+func (m *Maintenance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		var state MaintenanceState
+
+		err := json.NewDecoder(r.Body).Decode(&state)
+
+		if err == nil {
+			err = m.Set(state)
+		}
+
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(m.State())
+}
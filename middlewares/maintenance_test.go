@@ -0,0 +1,47 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceReadOnly(t *testing.T) {
+	maintenance := middlewares.NewMaintenance()
+	err := maintenance.Set(middlewares.MaintenanceState{Mode: middlewares.MaintenanceReadOnly, RetryAfter: 120})
+	assert.Nil(t, err)
+
+	handler := maintenance.Middleware()(http.HandlerFunc(okHandler))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/whoami", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/register", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "120", recorder.Header().Get("Retry-After"))
+}
+
+func TestMaintenanceAdminEndpoint(t *testing.T) {
+	maintenance := middlewares.NewMaintenance()
+
+	req := httptest.NewRequest("PUT", "/admin/maintenance", strings.NewReader(`{"mode": "full"}`))
+	recorder := httptest.NewRecorder()
+	maintenance.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, middlewares.MaintenanceFull, maintenance.State().Mode)
+
+	req = httptest.NewRequest("PUT", "/admin/maintenance", strings.NewReader(`{"mode": "unknown"}`))
+	recorder = httptest.NewRecorder()
+	maintenance.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
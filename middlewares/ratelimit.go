@@ -0,0 +1,389 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// RateLimitResult is the outcome of a single [RateLimiter.Allow] call.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is the time until the limit is fully available again.
+	ResetAfter time.Duration
+	// RetryAfter is the time until the next request is allowed, if it was denied.
+	RetryAfter time.Duration
+}
+
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (RateLimitResult, error)
+}
+
+// RateLimitRefunder is a [RateLimiter] that can give back a request it allowed.
+type RateLimitRefunder interface {
+	Refund(ctx context.Context, key string) error
+}
+
+// RateLimitState is what a [RateLimiter] persists for a key.
+// The meaning of the fields depends on the algorithm.
+type RateLimitState struct {
+	Value     float64
+	Previous  float64
+	UpdatedAt time.Time
+}
+
+type RateLimitStore interface {
+	// Update atomically replaces the state of key with the result of fn.
+	// found is false if the key doesn't exist or expired.
+	// The new state expires after ttl.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state RateLimitState, found bool) RateLimitState) error
+}
+
+// TokenBucket allows bursts of up to Burst requests, refilled at Limit requests per Period.
+type TokenBucket struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+	Store  RateLimitStore
+	Clock  Clock
+}
+
+func (b *TokenBucket) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	clock := b.Clock
+
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	now := clock.Now()
+	rate := float64(b.Limit) / b.Period.Seconds()
+	burst := float64(max(b.Burst, 1))
+	result := RateLimitResult{Limit: int(burst)}
+	ttl := time.Duration(burst / rate * float64(time.Second))
+
+	err := b.Store.Update(ctx, key, ttl, func(state RateLimitState, found bool) RateLimitState {
+		tokens := burst
+
+		if found {
+			elapsed := max(now.Sub(state.UpdatedAt).Seconds(), 0)
+			tokens = min(state.Value+elapsed*rate, burst)
+		}
+
+		if tokens >= 1 {
+			tokens--
+			result.Allowed = true
+		} else {
+			result.RetryAfter = secondsToDuration((1 - tokens) / rate)
+		}
+
+		result.Remaining = int(math.Floor(tokens))
+		result.ResetAfter = secondsToDuration((burst - tokens) / rate)
+
+		return RateLimitState{Value: tokens, UpdatedAt: now}
+	})
+
+	return result, err
+}
+
+// Refund implements RateLimitRefunder.
+func (b *TokenBucket) Refund(ctx context.Context, key string) error {
+	clock := b.Clock
+
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	now := clock.Now()
+	rate := float64(b.Limit) / b.Period.Seconds()
+	burst := float64(max(b.Burst, 1))
+	ttl := time.Duration(burst / rate * float64(time.Second))
+
+	return b.Store.Update(ctx, key, ttl, func(state RateLimitState, found bool) RateLimitState {
+		tokens := burst
+
+		if found {
+			elapsed := max(now.Sub(state.UpdatedAt).Seconds(), 0)
+			tokens = min(state.Value+elapsed*rate+1, burst)
+		}
+
+		return RateLimitState{Value: tokens, UpdatedAt: now}
+	})
+}
+
+// SlidingWindow allows Limit requests in any Window. It approximates the window
+// by weighting the count of the previous fixed window.
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+	Store  RateLimitStore
+	Clock  Clock
+}
+
+func (s *SlidingWindow) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	clock := s.Clock
+
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	now := clock.Now()
+	windowStart := now.Truncate(s.Window)
+	limit := float64(s.Limit)
+	result := RateLimitResult{Limit: s.Limit}
+
+	err := s.Store.Update(ctx, key, 2*s.Window, func(state RateLimitState, found bool) RateLimitState {
+		if !found || state.UpdatedAt.Before(windowStart) {
+			previous := 0.0
+
+			if found && state.UpdatedAt.Equal(windowStart.Add(-s.Window)) {
+				previous = state.Value
+			}
+
+			state = RateLimitState{Previous: previous, UpdatedAt: windowStart}
+		}
+
+		elapsed := now.Sub(windowStart)
+		weight := 1 - elapsed.Seconds()/s.Window.Seconds()
+		count := state.Previous*weight + state.Value
+
+		if count+1 <= limit {
+			state.Value++
+			count++
+			result.Allowed = true
+		} else if state.Value+1 > limit || state.Previous == 0 {
+			result.RetryAfter = s.Window - elapsed
+		} else {
+			// Wait until enough of the previous window has slid out.
+			neededWeight := (limit - state.Value - 1) / state.Previous
+			result.RetryAfter = max(time.Duration((1-neededWeight)*float64(s.Window))-elapsed, 0)
+		}
+
+		result.Remaining = max(int(math.Floor(limit-count)), 0)
+		result.ResetAfter = s.Window - elapsed
+
+		return state
+	})
+
+	return result, err
+}
+
+// Refund implements RateLimitRefunder.
+func (s *SlidingWindow) Refund(ctx context.Context, key string) error {
+	return s.Store.Update(ctx, key, 2*s.Window, func(state RateLimitState, found bool) RateLimitState {
+		if found && state.Value > 0 {
+			state.Value--
+		}
+
+		return state
+	})
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+type memoryRateLimitEntry struct {
+	state     RateLimitState
+	expiresAt time.Time
+}
+
+// MemoryRateLimitStore keeps rate limits in memory. Limits are not shared between instances.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryRateLimitEntry
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		entries: make(map[string]memoryRateLimitEntry),
+	}
+}
+
+// Update implements RateLimitStore.
+func (s *MemoryRateLimitStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(state RateLimitState, found bool) RateLimitState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		for key, entry := range s.entries {
+			if now.After(entry.expiresAt) {
+				delete(s.entries, key)
+			}
+		}
+
+		s.lastSweep = now
+	}
+
+	entry, found := s.entries[key]
+	found = found && now.Before(entry.expiresAt)
+
+	s.entries[key] = memoryRateLimitEntry{
+		state:     fn(entry.state, found),
+		expiresAt: now.Add(ttl),
+	}
+
+	return nil
+}
+
+// RateLimitKeyFunc returns the key a request is limited by.
+// An empty key skips rate limiting for the request. An error means the request
+// couldn't be read, it is answered with a 413 if the body was too large and a 400 otherwise.
+type RateLimitKeyFunc func(r *http.Request) (string, error)
+
+// RateLimitOptions configures the [RateLimit] middleware.
+type RateLimitOptions struct {
+	// Name separates the keys of different rate limits in a shared store.
+	Name    string
+	Limiter RateLimiter
+	Key     RateLimitKeyFunc
+	// Refund gives a request back if it returns true for the status of the response,
+	// e.g. to only count failed logins. Limiter has to implement [RateLimitRefunder].
+	Refund func(status int) bool
+}
+
+// RateLimit rejects requests over the limit with a 429 and a `Retry-After` header.
+// `RateLimit-Limit`, `RateLimit-Remaining` and `RateLimit-Reset` headers are added to every response.
+// If the store fails, requests are let through.
+func RateLimit(options RateLimitOptions) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, err := options.Key(r)
+
+			if err != nil {
+				writeReadBodyError(w, err)
+				return
+			}
+
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := options.Limiter.Allow(r.Context(), options.Name+":"+key)
+
+			if err != nil {
+				log.Printf("Rate limit %s failed: %v", options.Name, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			headers := w.Header()
+			headers.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			headers.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			headers.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+			if !result.Allowed {
+				headers.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			refunder, ok := options.Limiter.(RateLimitRefunder)
+
+			if options.Refund == nil || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rw := router.WrapResponseWriter(w)
+			next.ServeHTTP(rw, r)
+
+			status := rw.Status()
+
+			// Handlers that don't write anything respond with a 200.
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			if options.Refund(status) {
+				err = refunder.Refund(r.Context(), options.Name+":"+key)
+
+				if err != nil {
+					log.Printf("Rate limit %s failed to refund: %v", options.Name, err)
+				}
+			}
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// KeyByIP limits requests by the IP address of the client, as resolved by [RealIP].
+func KeyByIP(r *http.Request) (string, error) {
+	addr := ClientIP(r)
+
+	if !addr.IsValid() {
+		return r.RemoteAddr, nil
+	}
+
+	return addr.String(), nil
+}
+
+// KeyByHeader limits requests by the value of a header, e.g. an API key.
+// Requests without the header are not limited.
+func KeyByHeader(name string) RateLimitKeyFunc {
+	return func(r *http.Request) (string, error) {
+		return r.Header.Get(name), nil
+	}
+}
+
+// KeyByJSONField limits requests by a string field of a JSON body, e.g. the username of a login.
+// The body is restored for the next handler.
+func KeyByJSONField(field string) RateLimitKeyFunc {
+	return func(r *http.Request) (string, error) {
+		if r.Body == nil {
+			return "", nil
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+
+		if err != nil {
+			return "", fmt.Errorf("Failed to read body: %w", err)
+		}
+
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+		var fields map[string]any
+
+		if json.Unmarshal(body, &fields) != nil {
+			// Invalid bodies are rejected by the handler.
+			return "", nil
+		}
+
+		value, _ := fields[field].(string)
+
+		return value, nil
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
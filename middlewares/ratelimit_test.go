@@ -0,0 +1,180 @@
+package middlewares_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := &middlewares.TokenBucket{
+		Limit:  1,
+		Period: time.Second,
+		Burst:  2,
+		Store:  middlewares.NewMemoryRateLimitStore(),
+		Clock:  clock,
+	}
+
+	ctx := context.Background()
+
+	for range 2 {
+		res, err := limiter.Allow(ctx, "key")
+		assert.Nil(t, err)
+		assert.True(t, res.Allowed)
+	}
+
+	res, err := limiter.Allow(ctx, "key")
+	assert.Nil(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+
+	clock.now = clock.now.Add(time.Second)
+
+	res, err = limiter.Allow(ctx, "key")
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := &middlewares.SlidingWindow{
+		Limit:  2,
+		Window: time.Minute,
+		Store:  middlewares.NewMemoryRateLimitStore(),
+		Clock:  clock,
+	}
+
+	ctx := context.Background()
+
+	for range 2 {
+		res, err := limiter.Allow(ctx, "key")
+		assert.Nil(t, err)
+		assert.True(t, res.Allowed)
+	}
+
+	res, err := limiter.Allow(ctx, "key")
+	assert.Nil(t, err)
+	assert.False(t, res.Allowed)
+
+	// Half of the previous window still counts.
+	clock.now = clock.now.Add(90 * time.Second)
+
+	res, err = limiter.Allow(ctx, "key")
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+
+	res, err = limiter.Allow(ctx, "key")
+	assert.Nil(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 30*time.Second, res.RetryAfter)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	handler := middlewares.RateLimit(middlewares.RateLimitOptions{
+		Name:    "username",
+		Limiter: &middlewares.TokenBucket{Limit: 1, Period: time.Minute, Burst: 1, Store: middlewares.NewMemoryRateLimitStore()},
+		Key:     middlewares.KeyByJSONField("username"),
+	})(http.HandlerFunc(okHandler))
+
+	newRequest := func() *http.Request {
+		return httptest.NewRequest("POST", "/login", strings.NewReader(`{"username": "user1"}`))
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest())
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "1", recorder.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", recorder.Header().Get("RateLimit-Remaining"))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest())
+
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "60", recorder.Header().Get("Retry-After"))
+}
+
+func TestRateLimitMiddlewareBodyErrors(t *testing.T) {
+	handler := middlewares.MaxBodySize(4)(middlewares.RateLimit(middlewares.RateLimitOptions{
+		Name:    "username",
+		Limiter: &middlewares.TokenBucket{Limit: 1, Period: time.Minute, Burst: 1, Store: middlewares.NewMemoryRateLimitStore()},
+		Key:     middlewares.KeyByJSONField("username"),
+	})(http.HandlerFunc(okHandler)))
+
+	req := httptest.NewRequest("POST", "/login", io.MultiReader(strings.NewReader(`{"username": "user1"}`)))
+	req.ContentLength = -1
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "http:")
+
+	req = httptest.NewRequest("POST", "/login", iotest.ErrReader(errors.New("connection reset")))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "connection reset")
+}
+
+func TestRateLimitMiddlewareRefund(t *testing.T) {
+	for name, limiter := range map[string]middlewares.RateLimiter{
+		"token bucket":   &middlewares.TokenBucket{Limit: 1, Period: time.Minute, Burst: 2, Store: middlewares.NewMemoryRateLimitStore()},
+		"sliding window": &middlewares.SlidingWindow{Limit: 2, Window: time.Minute, Store: middlewares.NewMemoryRateLimitStore()},
+	} {
+		handler := middlewares.RateLimit(middlewares.RateLimitOptions{
+			Name:    "test",
+			Limiter: limiter,
+			Key:     middlewares.KeyByHeader("X-Key"),
+			Refund: func(status int) bool {
+				return status != http.StatusUnauthorized
+			},
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Fail") != "" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+
+		serve := func(fail bool) int {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Key", "key1")
+
+			if fail {
+				req.Header.Set("X-Fail", "1")
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			return recorder.Code
+		}
+
+		for range 5 {
+			assert.Equal(t, http.StatusOK, serve(false), name)
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, serve(true), name)
+		assert.Equal(t, http.StatusUnauthorized, serve(true), name)
+		assert.Equal(t, http.StatusTooManyRequests, serve(false), name)
+	}
+}
@@ -0,0 +1,235 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+type clientIPKey struct{}
+
+// RealIPOptions configures the [RealIP] middleware.
+type RealIPOptions struct {
+	// TrustedProxies lists the networks of proxies whose forwarding headers are trusted.
+	TrustedProxies []netip.Prefix
+}
+
+// ParsePrefixes parses a list of CIDRs. Single IP addresses are accepted as well.
+func ParsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid IP address %s: %w", cidr, err)
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CIDR %s: %w", cidr, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// forwardedHop is one proxy hop reported by a `Forwarded` or `X-Forwarded-*` header.
+type forwardedHop struct {
+	// For is invalid if the proxy hid or obfuscated the address.
+	For   netip.Addr
+	Proto string
+	Host  string
+}
+
+// RealIP resolves the IP address of the client when the server runs behind proxies.
+//
+// The `Forwarded` (RFC 7239) or `X-Forwarded-For` header is only used if the
+// immediate peer is a trusted proxy. The header is read from right to left and
+// the first address that isn't a trusted proxy is the client.
+// The scheme and host of the request are rewritten to the ones the client used.
+// The result can be read with [ClientIP].
+func RealIP(options RealIPOptions) router.Middleware {
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+
+		for _, prefix := range options.TrustedProxies {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer := remoteAddr(r)
+
+			if !peer.IsValid() || !isTrusted(peer) {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, peer)))
+				return
+			}
+
+			client := forwardedHop{For: peer}
+
+			for _, hop := range slices.Backward(forwardedHops(r.Header)) {
+				client.Proto, client.Host = hop.Proto, hop.Host
+
+				if !hop.For.IsValid() {
+					break
+				}
+
+				client.For = hop.For
+
+				if !isTrusted(hop.For) {
+					break
+				}
+			}
+
+			r = r.Clone(context.WithValue(r.Context(), clientIPKey{}, client.For))
+			r.RemoteAddr = netip.AddrPortFrom(client.For, 0).String()
+
+			if client.Proto == "http" || client.Proto == "https" {
+				r.URL.Scheme = client.Proto
+			}
+
+			if client.Host != "" {
+				r.Host = client.Host
+				r.URL.Host = client.Host
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the IP address of the client resolved by [RealIP].
+// Without the middleware it returns the address of the immediate peer.
+func ClientIP(r *http.Request) netip.Addr {
+	addr, ok := r.Context().Value(clientIPKey{}).(netip.Addr)
+
+	if ok {
+		return addr
+	}
+
+	return remoteAddr(r)
+}
+
+func remoteAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, _ := netip.ParseAddr(host)
+
+	return addr.Unmap()
+}
+
+// forwardedHops returns the hops from the `Forwarded` header, or from the
+// `X-Forwarded-*` headers if it isn't set. The closest proxy comes last.
+func forwardedHops(headers http.Header) []forwardedHop {
+	if forwarded := headers.Values("Forwarded"); len(forwarded) > 0 {
+		return parseForwarded(strings.Join(forwarded, ","))
+	}
+
+	forwardedFor := splitHeaderValues(headers.Values("X-Forwarded-For"))
+	protos := splitHeaderValues(headers.Values("X-Forwarded-Proto"))
+	hosts := splitHeaderValues(headers.Values("X-Forwarded-Host"))
+	hops := make([]forwardedHop, 0, len(forwardedFor))
+
+	for i, value := range forwardedFor {
+		hop := forwardedHop{For: parseForwardedNode(value)}
+
+		// Proxies that don't append to the lists are assumed to describe the first hop.
+		if len(protos) == len(forwardedFor) {
+			hop.Proto = protos[i]
+		} else if len(protos) > 0 {
+			hop.Proto = protos[len(protos)-1]
+		}
+
+		if len(hosts) == len(forwardedFor) {
+			hop.Host = hosts[i]
+		} else if len(hosts) > 0 {
+			hop.Host = hosts[len(hosts)-1]
+		}
+
+		hops = append(hops, hop)
+	}
+
+	return hops
+}
+
+// parseForwarded parses the elements of a `Forwarded` header, e.g.
+// `for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"`.
+func parseForwarded(value string) []forwardedHop {
+	hops := make([]forwardedHop, 0)
+
+	for _, element := range strings.Split(value, ",") {
+		hop := forwardedHop{}
+
+		for _, pair := range strings.Split(element, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			value = strings.Trim(value, `"`)
+
+			switch strings.ToLower(key) {
+			case "for":
+				hop.For = parseForwardedNode(value)
+			case "proto":
+				hop.Proto = strings.ToLower(value)
+			case "host":
+				hop.Host = value
+			}
+		}
+
+		hops = append(hops, hop)
+	}
+
+	return hops
+}
+
+// parseForwardedNode parses an address with an optional port.
+// Obfuscated identifiers like `unknown` or `_hidden` result in an invalid address.
+func parseForwardedNode(node string) netip.Addr {
+	node = strings.TrimSpace(node)
+
+	if addrPort, err := netip.ParseAddrPort(node); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(node, "[]"))
+
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return addr.Unmap()
+}
+
+func splitHeaderValues(values []string) []string {
+	result := make([]string, 0)
+
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			result = append(result, strings.TrimSpace(part))
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,62 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveRealIP(t *testing.T, req *http.Request) *http.Request {
+	proxies, err := middlewares.ParsePrefixes([]string{"10.0.0.0/8", "2001:db8::1"})
+	assert.Nil(t, err)
+
+	var resolved *http.Request
+
+	handler := middlewares.RealIP(middlewares.RealIPOptions{TrustedProxies: proxies})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resolved = r
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	return resolved
+}
+
+func TestRealIPIgnoresUntrustedPeer(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	r := serveRealIP(t, req)
+
+	assert.Equal(t, netip.MustParseAddr("198.51.100.1"), middlewares.ClientIP(r))
+}
+
+func TestRealIPXForwardedFor(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("X-Forwarded-For", "192.0.2.1, 203.0.113.7, 10.0.0.1")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "api.example.com")
+
+	r := serveRealIP(t, req)
+
+	assert.Equal(t, netip.MustParseAddr("203.0.113.7"), middlewares.ClientIP(r))
+	assert.Equal(t, "https", r.URL.Scheme)
+	assert.Equal(t, "api.example.com", r.Host)
+}
+
+func TestRealIPForwarded(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "[2001:db8::1]:443"
+	req.Header.Set("Forwarded", `for="[2001:db8:cafe::17]:4711";proto=https;host=api.example.com, for=10.1.2.3`)
+
+	r := serveRealIP(t, req)
+
+	assert.Equal(t, netip.MustParseAddr("2001:db8:cafe::17"), middlewares.ClientIP(r))
+	assert.Equal(t, "https", r.URL.Scheme)
+	assert.Equal(t, "api.example.com", r.Host)
+}
@@ -0,0 +1,239 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+// NoncePlaceholder is replaced with the request's nonce in a Content-Security-Policy.
+const NoncePlaceholder = "{nonce}"
+
+type cspNonceKey struct{}
+
+// securityHeaderKeys are the headers [SecurityHeaders] can set.
+var securityHeaderKeys = []string{
+	"Strict-Transport-Security",
+	"X-Frame-Options",
+	"Referrer-Policy",
+	"Permissions-Policy",
+	"Cross-Origin-Opener-Policy",
+	"Cross-Origin-Embedder-Policy",
+	"Content-Security-Policy",
+	"Content-Security-Policy-Report-Only",
+}
+
+// SecurityHeadersOptions configures the [SecurityHeaders] middleware.
+// Empty fields are not sent.
+type SecurityHeadersOptions struct {
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	// ContentSecurityPolicy can use [NoncePlaceholder], e.g. `script-src {nonce}`.
+	ContentSecurityPolicy string
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only.
+	CSPReportOnly bool
+	// CSPReportURI is added to the policy as the `report-uri` directive.
+	CSPReportURI string
+	// FrameAncestors is added to the policy as the `frame-ancestors` directive.
+	// `'none'` and `'self'` are also sent as X-Frame-Options for older browsers.
+	FrameAncestors string
+
+	ReferrerPolicy            string
+	PermissionsPolicy         string
+	CrossOriginOpenerPolicy   string
+	CrossOriginEmbedderPolicy string
+}
+
+// DefaultSecurityHeadersOptions returns strict options for an API that doesn't serve HTML.
+func DefaultSecurityHeadersOptions() SecurityHeadersOptions {
+	return SecurityHeadersOptions{
+		HSTSMaxAge:                time.Hour * 24 * 365,
+		HSTSIncludeSubdomains:     true,
+		ContentSecurityPolicy:     "default-src 'none'",
+		FrameAncestors:            "'none'",
+		ReferrerPolicy:            "no-referrer",
+		PermissionsPolicy:         "camera=(), microphone=(), geolocation=()",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginEmbedderPolicy: "require-corp",
+	}
+}
+
+// SecurityHeaders sets security related response headers.
+//
+// Headers are overwritten and the ones the options leave empty are removed, so using it
+// again on a group replaces the options of the root router.
+// A nonce is generated once per request and can be read with [CSPNonce].
+func SecurityHeaders(options SecurityHeadersOptions) router.Middleware {
+	static := make(http.Header)
+	static.Set("X-Content-Type-Options", "nosniff")
+
+	if options.HSTSMaxAge > 0 {
+		hsts := fmt.Sprintf("max-age=%d", int(options.HSTSMaxAge.Seconds()))
+
+		if options.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+
+		if options.HSTSPreload {
+			hsts += "; preload"
+		}
+
+		static.Set("Strict-Transport-Security", hsts)
+	}
+
+	switch options.FrameAncestors {
+	case "'none'":
+		static.Set("X-Frame-Options", "DENY")
+	case "'self'":
+		static.Set("X-Frame-Options", "SAMEORIGIN")
+	}
+
+	setIfNotEmpty := func(key, value string) {
+		if value != "" {
+			static.Set(key, value)
+		}
+	}
+
+	setIfNotEmpty("Referrer-Policy", options.ReferrerPolicy)
+	setIfNotEmpty("Permissions-Policy", options.PermissionsPolicy)
+	setIfNotEmpty("Cross-Origin-Opener-Policy", options.CrossOriginOpenerPolicy)
+	setIfNotEmpty("Cross-Origin-Embedder-Policy", options.CrossOriginEmbedderPolicy)
+
+	directives := make([]string, 0)
+
+	if options.ContentSecurityPolicy != "" {
+		directives = append(directives, strings.TrimSuffix(strings.TrimSpace(options.ContentSecurityPolicy), ";"))
+	}
+
+	if options.FrameAncestors != "" {
+		directives = append(directives, "frame-ancestors "+options.FrameAncestors)
+	}
+
+	if options.CSPReportURI != "" {
+		directives = append(directives, "report-uri "+options.CSPReportURI)
+	}
+
+	policy := strings.Join(directives, "; ")
+
+	cspHeader := "Content-Security-Policy"
+
+	if options.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers := w.Header()
+
+			for _, key := range securityHeaderKeys {
+				headers.Del(key)
+			}
+
+			for key, values := range static {
+				headers[key] = values
+			}
+
+			// An earlier SecurityHeaders already generated the nonce the handler sees.
+			ctx := r.Context()
+			nonce := CSPNonce(ctx)
+
+			if nonce == "" {
+				var err error
+
+				nonce, err = newNonce()
+
+				if err != nil {
+					http.Error(w, "Failed to generate nonce", http.StatusInternalServerError)
+					return
+				}
+
+				ctx = context.WithValue(ctx, cspNonceKey{}, nonce)
+			}
+
+			if policy != "" {
+				headers.Set(cspHeader, strings.ReplaceAll(policy, NoncePlaceholder, "'nonce-"+nonce+"'"))
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// CSPNonce returns the nonce of the request's Content-Security-Policy,
+// to be used in the `nonce` attribute of script and style tags.
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+
+	return nonce
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// CSPReportHandler logs Content-Security-Policy violation reports.
+// It accepts both the `application/csp-report` format of `report-uri`
+// and the `application/reports+json` format of the Reporting API.
+// Anyone can send reports, so mount it behind [RateLimit] and a small [MaxBodySize].
+func CSPReportHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+
+	if err != nil {
+		writeReadBodyError(w, err)
+		return
+	}
+
+	reports := make([]json.RawMessage, 0)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/reports+json") {
+		var batch []struct {
+			Type string          `json:"type"`
+			Body json.RawMessage `json:"body"`
+		}
+
+		err = json.Unmarshal(body, &batch)
+
+		for _, report := range batch {
+			if report.Type == "csp-violation" {
+				reports = append(reports, report.Body)
+			}
+		}
+	} else {
+		var report struct {
+			Report json.RawMessage `json:"csp-report"`
+		}
+
+		err = json.Unmarshal(body, &report)
+		reports = append(reports, report.Report)
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	for _, report := range reports {
+		log.Printf("CSP violation: %s", report)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
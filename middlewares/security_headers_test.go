@@ -0,0 +1,101 @@
+package middlewares_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	options := middlewares.DefaultSecurityHeadersOptions()
+	options.ContentSecurityPolicy = "script-src {nonce}"
+
+	var nonce string
+
+	handler := middlewares.SecurityHeaders(options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = middlewares.CSPNonce(r.Context())
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.NotEmpty(t, nonce)
+	assert.Equal(t, "script-src 'nonce-"+nonce+"'; frame-ancestors 'none'", recorder.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", recorder.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "max-age=31536000; includeSubDomains", recorder.Header().Get("Strict-Transport-Security"))
+}
+
+func TestSecurityHeadersReportOnly(t *testing.T) {
+	options := middlewares.DefaultSecurityHeadersOptions()
+	options.CSPReportOnly = true
+	options.CSPReportURI = "/csp-report"
+
+	recorder := httptest.NewRecorder()
+	middlewares.SecurityHeaders(options)(http.HandlerFunc(okHandler)).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, "", recorder.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "default-src 'none'; frame-ancestors 'none'; report-uri /csp-report", recorder.Header().Get("Content-Security-Policy-Report-Only"))
+}
+
+func TestSecurityHeadersOverride(t *testing.T) {
+	root := middlewares.DefaultSecurityHeadersOptions()
+	root.ContentSecurityPolicy = "script-src {nonce}"
+
+	var nonce string
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		nonce = middlewares.CSPNonce(r.Context())
+	}
+
+	// The group sends no CSP, HSTS or X-Frame-Options.
+	recorder := httptest.NewRecorder()
+	middlewares.SecurityHeaders(root)(middlewares.SecurityHeaders(middlewares.SecurityHeadersOptions{
+		ReferrerPolicy: "same-origin",
+	})(http.HandlerFunc(handler))).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, "", recorder.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "", recorder.Header().Get("Strict-Transport-Security"))
+	assert.Equal(t, "", recorder.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "", recorder.Header().Get("Cross-Origin-Embedder-Policy"))
+	assert.Equal(t, "same-origin", recorder.Header().Get("Referrer-Policy"))
+	assert.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+
+	// The group policy uses the nonce the handler sees.
+	recorder = httptest.NewRecorder()
+	middlewares.SecurityHeaders(root)(middlewares.SecurityHeaders(middlewares.SecurityHeadersOptions{
+		ContentSecurityPolicy: "style-src {nonce}",
+		CSPReportOnly:         true,
+	})(http.HandlerFunc(handler))).ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.NotEmpty(t, nonce)
+	assert.Equal(t, "", recorder.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "style-src 'nonce-"+nonce+"'", recorder.Header().Get("Content-Security-Policy-Report-Only"))
+}
+
+func TestCSPReportHandler(t *testing.T) {
+	body := `{"csp-report": {"document-uri": "https://example.com", "violated-directive": "script-src"}}`
+	req := httptest.NewRequest("POST", "/csp-report", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/csp-report")
+
+	recorder := httptest.NewRecorder()
+	middlewares.CSPReportHandler(recorder, req)
+
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+}
+
+func TestCSPReportHandlerRejectsLargeBodies(t *testing.T) {
+	req := httptest.NewRequest("POST", "/csp-report", io.MultiReader(strings.NewReader(`{"csp-report": {}}`)))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/csp-report")
+
+	recorder := httptest.NewRecorder()
+	middlewares.MaxBodySize(4)(http.HandlerFunc(middlewares.CSPReportHandler)).ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+}
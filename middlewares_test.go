@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dpbrackin/ready-set-go/auth"
+	"github.com/dpbrackin/ready-set-go/middlewares"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// testAuthRepository has a single user, `alice` with the password `password`.
+type testAuthRepository struct {
+	mu      sync.Mutex
+	lookups int
+	hash    []byte
+}
+
+func (r *testAuthRepository) GetUserByUsername(ctx context.Context, username string) (auth.UserWithPassword, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lookups++
+
+	if username != "alice" {
+		return auth.UserWithPassword{}, auth.ErrUserNotFound
+	}
+
+	return auth.UserWithPassword{User: auth.User{ID: 1, Username: "alice"}, Password: string(r.hash)}, nil
+}
+
+func (r *testAuthRepository) AddUser(ctx context.Context, params auth.UserWithPassword) error {
+	return errors.New("not implemented")
+}
+
+func (r *testAuthRepository) UpdateUserPassword(ctx context.Context, userID int32, password string) error {
+	return errors.New("not implemented")
+}
+
+// failingSessionStore fails like a database that is down.
+type failingSessionStore struct {
+	*auth.MemorySessionStore
+}
+
+func (failingSessionStore) GetSession(ctx context.Context, sessionID string) (auth.Session, error) {
+	return auth.Session{}, errors.New("connection refused")
+}
+
+func newTestAuthService(t *testing.T, sessions auth.SessionStore) (*auth.AuthService, *testAuthRepository, *testClock) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	assert.Nil(t, err)
+
+	repository := &testAuthRepository{hash: hash}
+	clock := &testClock{now: time.Now()}
+
+	srv := auth.NewAuthService(auth.NewAuthServiceParams{
+		Repository:           repository,
+		Sessions:             sessions,
+		Clock:                clock,
+		SessionIdleTimeout:   time.Hour,
+		SessionRenewalWindow: 10 * time.Minute,
+	})
+
+	return srv, repository, clock
+}
+
+// stubAuthenticator returns a fixed result and records that it was called.
+type stubAuthenticator struct {
+	name   string
+	ok     bool
+	err    error
+	calls  *[]string
+	method auth.Method
+}
+
+func (a stubAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request) (auth.Authentication, bool, error) {
+	*a.calls = append(*a.calls, a.name)
+
+	return auth.Authentication{User: auth.User{ID: 1}, Method: a.method}, a.ok, a.err
+}
+
+func (a stubAuthenticator) Challenge() string {
+	return a.name
+}
+
+// authenticationHandler responds with the method the request was authenticated with.
+func authenticationHandler(w http.ResponseWriter, r *http.Request) {
+	authentication, _ := auth.FromContext(r.Context())
+
+	w.Write([]byte(authentication.Method))
+}
+
+func TestAuthMiddlewareTriesAuthenticatorsInOrder(t *testing.T) {
+	var calls []string
+
+	handler := AuthMiddleware(
+		stubAuthenticator{name: "first", calls: &calls},
+		stubAuthenticator{name: "second", ok: true, calls: &calls, method: auth.MethodBearer},
+		stubAuthenticator{name: "third", ok: true, calls: &calls, method: auth.MethodBasic},
+	)(http.HandlerFunc(authenticationHandler))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/whoami", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Equal(t, string(auth.MethodBearer), recorder.Body.String())
+}
+
+func TestAuthMiddlewareChallenges(t *testing.T) {
+	srv, _, _ := newTestAuthService(t, auth.NewMemorySessionStore())
+
+	handler := AuthMiddleware(
+		SessionCookieAuthenticator{Srv: srv},
+		BearerAuthenticator{Srv: srv, Realm: "test"},
+		BasicAuthenticator{Srv: srv, Realm: "test"},
+	)(http.HandlerFunc(authenticationHandler))
+
+	challenge := `Bearer realm="test", Basic realm="test", charset="UTF-8"`
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/whoami", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, challenge, recorder.Header().Get("WWW-Authenticate"))
+
+	req := httptest.NewRequest("GET", "/whoami", nil)
+	req.SetBasicAuth("alice", "wrong password")
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, challenge, recorder.Header().Get("WWW-Authenticate"))
+}
+
+func TestAuthMiddlewareErrors(t *testing.T) {
+	tests := map[error]int{
+		auth.ErrSessionExpired:         http.StatusUnauthorized,
+		auth.ErrInvalidCredentials:     http.StatusUnauthorized,
+		errors.New("connection reset"): http.StatusInternalServerError,
+	}
+
+	for err, status := range tests {
+		var calls []string
+
+		handler := AuthMiddleware(
+			stubAuthenticator{name: "failing", ok: true, err: err, calls: &calls},
+		)(http.HandlerFunc(authenticationHandler))
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/whoami", nil))
+
+		assert.Equal(t, status, recorder.Code, err.Error())
+	}
+
+	srv, _, _ := newTestAuthService(t, failingSessionStore{auth.NewMemorySessionStore()})
+
+	handler := AuthMiddleware(
+		SessionCookieAuthenticator{Srv: srv},
+	)(http.HandlerFunc(authenticationHandler))
+
+	req := httptest.NewRequest("GET", "/whoami", nil)
+	req.AddCookie(&http.Cookie{Name: "sessionID", Value: "token"})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "connection refused")
+}
+
+func TestAuthMiddlewareMethods(t *testing.T) {
+	srv, _, _ := newTestAuthService(t, auth.NewMemorySessionStore())
+
+	session, err := srv.CreateSession(context.Background(), auth.User{ID: 1, Username: "alice"}, auth.Device{})
+	assert.Nil(t, err)
+
+	handler := AuthMiddleware(
+		SessionCookieAuthenticator{Srv: srv},
+		BearerAuthenticator{Srv: srv, Realm: "test"},
+		BasicAuthenticator{Srv: srv, Realm: "test"},
+	)(http.HandlerFunc(authenticationHandler))
+
+	cookie := httptest.NewRequest("GET", "/whoami", nil)
+	cookie.AddCookie(&http.Cookie{Name: "sessionID", Value: session.Token})
+
+	bearer := httptest.NewRequest("GET", "/whoami", nil)
+	bearer.Header.Set("Authorization", "Bearer "+session.Token)
+
+	basic := httptest.NewRequest("GET", "/whoami", nil)
+	basic.SetBasicAuth("alice", "password")
+
+	tests := map[*http.Request]auth.Method{
+		cookie: auth.MethodSession,
+		bearer: auth.MethodBearer,
+		basic:  auth.MethodBasic,
+	}
+
+	for req, method := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusOK, recorder.Code, method)
+		assert.Equal(t, string(method), recorder.Body.String())
+	}
+}
+
+func TestSessionCookieAuthenticatorRenewsCookie(t *testing.T) {
+	srv, _, clock := newTestAuthService(t, auth.NewMemorySessionStore())
+
+	session, err := srv.CreateSession(context.Background(), auth.User{ID: 1, Username: "alice"}, auth.Device{})
+	assert.Nil(t, err)
+
+	handler := AuthMiddleware(
+		SessionCookieAuthenticator{Srv: srv, CookieSameSite: http.SameSiteLaxMode},
+	)(http.HandlerFunc(authenticationHandler))
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/whoami", nil)
+		req.AddCookie(&http.Cookie{Name: "sessionID", Value: session.Token})
+
+		return req
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest())
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Empty(t, recorder.Result().Cookies())
+
+	// Within the renewal window of the idle timeout.
+	clock.Add(55 * time.Minute)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest())
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	cookies := recorder.Result().Cookies()
+
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "sessionID", cookies[0].Name)
+		assert.Equal(t, session.Token, cookies[0].Value)
+		assert.True(t, cookies[0].Expires.After(session.ExpiresAt))
+	}
+}
+
+func TestBasicAuthIsRateLimited(t *testing.T) {
+	srv, repository, _ := newTestAuthService(t, auth.NewMemorySessionStore())
+
+	handler := middlewares.RateLimit(middlewares.RateLimitOptions{
+		Name:    "username",
+		Limiter: &middlewares.SlidingWindow{Limit: 2, Window: time.Minute, Store: middlewares.NewMemoryRateLimitStore()},
+		Key:     keyByBasicAuthUsername,
+		Refund:  isAuthenticated,
+	})(AuthMiddleware(
+		BasicAuthenticator{Srv: srv, Realm: "test"},
+	)(http.HandlerFunc(authenticationHandler)))
+
+	serve := func(username, password string) int {
+		req := httptest.NewRequest("GET", "/whoami", nil)
+		req.SetBasicAuth(username, password)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		return recorder.Code
+	}
+
+	// Machine users aren't throttled by their own successful requests.
+	for range 10 {
+		assert.Equal(t, http.StatusOK, serve("alice", "password"))
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve("alice", "wrong password"))
+	assert.Equal(t, http.StatusOK, serve("alice", "password"))
+	assert.Equal(t, http.StatusUnauthorized, serve("ALICE", "wrong password"))
+
+	// Two failures use up the limit, for all spellings of the username.
+	lookups := repository.lookups
+	assert.Equal(t, http.StatusTooManyRequests, serve("ａｌｉｃｅ", "wrong password"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("alice", "password"))
+	assert.Equal(t, lookups, repository.lookups)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/whoami", strings.NewReader("")))
+
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestLoginIsRateLimitedByFoldedUsername(t *testing.T) {
+	srv, repository, _ := newTestAuthService(t, auth.NewMemorySessionStore())
+	handlers := AuthHandlers{Srv: srv}
+
+	handler := middlewares.RateLimit(middlewares.RateLimitOptions{
+		Name:    "username",
+		Limiter: &middlewares.SlidingWindow{Limit: 2, Window: time.Minute, Store: middlewares.NewMemoryRateLimitStore()},
+		Key:     keyByFoldedUsername(middlewares.KeyByJSONField("username")),
+	})(http.HandlerFunc(handlers.Login))
+
+	for i, username := range []string{"alice", "ALICE", "ａｌｉｃｅ"} {
+		body := `{"username":"` + username + `","password":"wrong password"}`
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/login", strings.NewReader(body)))
+
+		if i < 2 {
+			assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		} else {
+			assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+		}
+	}
+
+	assert.Equal(t, 2, repository.lookups)
+}
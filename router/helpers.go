@@ -24,3 +24,23 @@ func parsePattern(pattern string) (method, host, path string) {
 
 	return
 }
+
+// pathShape removes the names of the wildcards of path, e.g. `/a/{id}` becomes `/a/{}`,
+// so paths that match the same requests have the same shape.
+func pathShape(path string) string {
+	segments := strings.Split(path, "/")
+
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || segment == "{$}" {
+			continue
+		}
+
+		if strings.HasSuffix(segment, "...}") {
+			segments[i] = "{...}"
+		} else {
+			segments[i] = "{}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,305 @@
+package router
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseWriter is an [http.ResponseWriter] that records what was written to it.
+// Create one with [WrapResponseWriter].
+type ResponseWriter interface {
+	http.ResponseWriter
+	// Status returns the status code that was sent, or 0 if the response hasn't started.
+	Status() int
+	// BytesWritten returns the number of body bytes that were written.
+	BytesWritten() int64
+	// FirstByteAt returns when the first body byte was written.
+	FirstByteAt() time.Time
+	// WriteErr returns the first error returned by a write.
+	WriteErr() error
+	// Unwrap returns the wrapped writer, for [http.ResponseController].
+	Unwrap() http.ResponseWriter
+}
+
+type responseWriter struct {
+	w            http.ResponseWriter
+	hooks        Hooks
+	status       int
+	bytesWritten int64
+	firstByteAt  time.Time
+	writeErr     error
+}
+
+// Hooks replace methods of a writer created with [WrapResponseWriterWithHooks].
+// Nil hooks call the wrapped writer. Flush and Hijack are only called if the
+// wrapped writer implements [http.Flusher] and [http.Hijacker].
+type Hooks struct {
+	Header      func() http.Header
+	WriteHeader func(code int)
+	Write       func(b []byte) (int, error)
+	Flush       func()
+	Hijack      func() (net.Conn, *bufio.ReadWriter, error)
+}
+
+// WrapResponseWriter wraps w in a [ResponseWriter].
+// The result implements the same optional interfaces as w:
+// [http.Flusher], [http.Hijacker], [io.ReaderFrom] and [http.Pusher].
+func WrapResponseWriter(w http.ResponseWriter) ResponseWriter {
+	return WrapResponseWriterWithHooks(w, Hooks{})
+}
+
+// WrapResponseWriterWithHooks is [WrapResponseWriter] for middlewares that change
+// the response, like compression. Build writers with it instead of embedding
+// [http.ResponseWriter], which hides the optional interfaces of w.
+// If Write is hooked, ReadFrom copies the body through it.
+func WrapResponseWriterWithHooks(w http.ResponseWriter, hooks Hooks) ResponseWriter {
+	rw := &responseWriter{w: w, hooks: hooks}
+
+	_, isFlusher := w.(http.Flusher)
+	_, isHijacker := w.(http.Hijacker)
+	_, isReaderFrom := w.(io.ReaderFrom)
+	_, isPusher := w.(http.Pusher)
+
+	f := flusher{rw}
+	h := hijacker{rw}
+	rf := readerFrom{rw}
+	p := pusher{rw}
+
+	switch {
+	case isFlusher && isHijacker && isReaderFrom && isPusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{rw, f, h, rf, p}
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, f, h, rf}
+	case isFlusher && isHijacker && isPusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{rw, f, h, p}
+	case isFlusher && isReaderFrom && isPusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+		}{rw, f, rf, p}
+	case isHijacker && isReaderFrom && isPusher:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{rw, h, rf, p}
+	case isFlusher && isHijacker:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{rw, f, h}
+	case isFlusher && isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{rw, f, rf}
+	case isFlusher && isPusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+		}{rw, f, p}
+	case isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, h, rf}
+	case isHijacker && isPusher:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+		}{rw, h, p}
+	case isReaderFrom && isPusher:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+			http.Pusher
+		}{rw, rf, p}
+	case isFlusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+		}{rw, f}
+	case isHijacker:
+		return struct {
+			*responseWriter
+			http.Hijacker
+		}{rw, h}
+	case isReaderFrom:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+		}{rw, rf}
+	case isPusher:
+		return struct {
+			*responseWriter
+			http.Pusher
+		}{rw, p}
+	default:
+		return rw
+	}
+}
+
+func (rw *responseWriter) Header() http.Header {
+	if rw.hooks.Header != nil {
+		return rw.hooks.Header()
+	}
+
+	return rw.w.Header()
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	// Informational responses can be followed by the final one.
+	if rw.status == 0 && code >= 200 {
+		rw.status = code
+	}
+
+	if rw.hooks.WriteHeader != nil {
+		rw.hooks.WriteHeader(code)
+		return
+	}
+
+	rw.w.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.beforeWrite(len(b) > 0)
+
+	var n int
+	var err error
+
+	if rw.hooks.Write != nil {
+		n, err = rw.hooks.Write(b)
+	} else {
+		n, err = rw.w.Write(b)
+	}
+
+	rw.afterWrite(int64(n), err)
+
+	return n, err
+}
+
+func (rw *responseWriter) beforeWrite(hasBody bool) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+
+	if hasBody && rw.firstByteAt.IsZero() {
+		rw.firstByteAt = time.Now()
+	}
+}
+
+func (rw *responseWriter) afterWrite(n int64, err error) {
+	rw.bytesWritten += n
+
+	if err != nil && rw.writeErr == nil {
+		rw.writeErr = err
+	}
+}
+
+func (rw *responseWriter) Status() int {
+	return rw.status
+}
+
+func (rw *responseWriter) BytesWritten() int64 {
+	return rw.bytesWritten
+}
+
+func (rw *responseWriter) FirstByteAt() time.Time {
+	return rw.firstByteAt
+}
+
+func (rw *responseWriter) WriteErr() error {
+	return rw.writeErr
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+type flusher struct {
+	rw *responseWriter
+}
+
+func (f flusher) Flush() {
+	f.rw.beforeWrite(false)
+
+	if f.rw.hooks.Flush != nil {
+		f.rw.hooks.Flush()
+		return
+	}
+
+	f.rw.w.(http.Flusher).Flush()
+}
+
+type hijacker struct {
+	rw *responseWriter
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijack := h.rw.w.(http.Hijacker).Hijack
+
+	if h.rw.hooks.Hijack != nil {
+		hijack = h.rw.hooks.Hijack
+	}
+
+	conn, buf, err := hijack()
+
+	if err == nil && h.rw.status == 0 {
+		h.rw.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, buf, err
+}
+
+type readerFrom struct {
+	rw *responseWriter
+}
+
+func (rf readerFrom) ReadFrom(r io.Reader) (int64, error) {
+	// The body has to go through the hook, *responseWriter has no ReadFrom, so io.Copy calls Write.
+	if rf.rw.hooks.Write != nil {
+		return io.Copy(rf.rw, r)
+	}
+
+	rf.rw.beforeWrite(true)
+
+	n, err := rf.rw.w.(io.ReaderFrom).ReadFrom(r)
+	rf.rw.afterWrite(n, err)
+
+	return n, err
+}
+
+type pusher struct {
+	rw *responseWriter
+}
+
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	return p.rw.w.(http.Pusher).Push(target, opts)
+}
@@ -0,0 +1,118 @@
+package router_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dpbrackin/ready-set-go/router"
+)
+
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+func TestResponseWriterRecordsWrites(t *testing.T) {
+	rw := router.WrapResponseWriter(httptest.NewRecorder())
+
+	rw.Write([]byte("hello"))
+	rw.WriteHeader(http.StatusNotFound)
+
+	if rw.Status() != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rw.Status())
+	}
+
+	if rw.BytesWritten() != 5 {
+		t.Errorf("Expected 5 bytes, got %d", rw.BytesWritten())
+	}
+
+	if rw.FirstByteAt().IsZero() {
+		t.Errorf("Expected first byte time to be set")
+	}
+}
+
+func TestResponseWriterPreservesInterfaces(t *testing.T) {
+	rw := router.WrapResponseWriter(httptest.NewRecorder())
+
+	if _, ok := rw.(http.Flusher); !ok {
+		t.Errorf("Expected http.Flusher to be preserved")
+	}
+
+	if _, ok := rw.(http.Hijacker); ok {
+		t.Errorf("Expected http.Hijacker not to be added")
+	}
+
+	if _, ok := rw.(io.ReaderFrom); ok {
+		t.Errorf("Expected io.ReaderFrom not to be added")
+	}
+
+	rw = router.WrapResponseWriter(readerFromRecorder{httptest.NewRecorder()})
+
+	readerFrom, ok := rw.(io.ReaderFrom)
+
+	if !ok {
+		t.Fatalf("Expected io.ReaderFrom to be preserved")
+	}
+
+	readerFrom.ReadFrom(strings.NewReader("hello"))
+
+	if rw.BytesWritten() != 5 {
+		t.Errorf("Expected 5 bytes, got %d", rw.BytesWritten())
+	}
+}
+
+func TestResponseWriterSupportsResponseController(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rw := router.WrapResponseWriter(recorder)
+
+	err := http.NewResponseController(rw).Flush()
+
+	if err != nil {
+		t.Errorf("Expected flush to succeed, got %v", err)
+	}
+
+	if !recorder.Flushed {
+		t.Errorf("Expected recorder to be flushed")
+	}
+}
+
+func TestResponseWriterHooks(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	flushed := false
+
+	rw := router.WrapResponseWriterWithHooks(readerFromRecorder{recorder}, router.Hooks{
+		Write: func(b []byte) (int, error) {
+			return recorder.Write([]byte(strings.ToUpper(string(b))))
+		},
+		Flush: func() {
+			flushed = true
+		},
+	})
+
+	readerFrom, ok := rw.(io.ReaderFrom)
+
+	if !ok {
+		t.Fatalf("Expected io.ReaderFrom to be preserved")
+	}
+
+	readerFrom.ReadFrom(strings.NewReader("hello"))
+	rw.(http.Flusher).Flush()
+
+	if body := recorder.Body.String(); body != "HELLO" {
+		t.Errorf("Expected ReadFrom to use the Write hook, got %q", body)
+	}
+
+	if rw.BytesWritten() != 5 {
+		t.Errorf("Expected 5 bytes, got %d", rw.BytesWritten())
+	}
+
+	if !flushed || recorder.Flushed {
+		t.Errorf("Expected the Flush hook to replace Flush")
+	}
+}
@@ -4,20 +4,29 @@ package router
 import (
 	"net/http"
 	"slices"
+	"strings"
 )
 
 type Root struct {
 	mux         *http.ServeMux
 	middlewares []Middleware
-	routes      map[string]http.Handler
-	groups      []*RouteGroup
+	// routes of the router and its groups, in the order they were registered.
+	routes []route
+	groups []*RouteGroup
 }
 
 // RouteGroup groups related routes under a common prefix and use the same middlewares.
 type RouteGroup struct {
+	root        *Root
 	prefix      string
 	middlewares []Middleware
-	routes      map[string]http.Handler
+}
+
+type route struct {
+	pattern string
+	handler http.Handler
+	// group is nil for routes of the root router.
+	group *RouteGroup
 }
 
 type Middleware func(next http.Handler) http.Handler
@@ -34,17 +43,28 @@ func NewRootRouter() *Root {
 	return &Root{
 		middlewares: make([]Middleware, 0),
 		groups:      make([]*RouteGroup, 0),
-		routes:      make(map[string]http.Handler),
 		mux:         http.NewServeMux(),
 	}
 }
 
+// addRoute registers a route, replacing a route of the same group with the same pattern.
+func (router *Root) addRoute(pattern string, handler http.Handler, group *RouteGroup) {
+	for i, existing := range router.routes {
+		if existing.pattern == pattern && existing.group == group {
+			router.routes[i].handler = handler
+			return
+		}
+	}
+
+	router.routes = append(router.routes, route{pattern: pattern, handler: handler, group: group})
+}
+
 // Group adds a new RouteGroup to the router.
 func (router *Root) Group(prefix string) *RouteGroup {
 	group := &RouteGroup{
+		root:        router,
 		prefix:      prefix,
 		middlewares: make([]Middleware, 0),
-		routes:      make(map[string]http.Handler),
 	}
 
 	router.groups = append(router.groups, group)
@@ -58,9 +78,10 @@ func (router *Root) Use(middleware Middleware) {
 	router.middlewares = append(router.middlewares, middleware)
 }
 
-// RouteFunc adds a route that is handled by a function
-func (router *Root) RouteFunc(route string, f func(http.ResponseWriter, *http.Request)) {
-	router.routes[route] = http.HandlerFunc(f)
+// RouteFunc adds a route that is handled by a function.
+// The middlewares are only used for this route and run after the router's middlewares.
+func (router *Root) RouteFunc(route string, f func(http.ResponseWriter, *http.Request), middlewares ...Middleware) {
+	router.addRoute(route, applyMiddlewares(http.HandlerFunc(f), middlewares), nil)
 }
 
 // Use adds a middleware that is used for all routes in the router.
@@ -70,27 +91,122 @@ func (group *RouteGroup) Use(middleware Middleware) {
 }
 
 // RouteFunc adds a route that is handled by a function to the group.
-func (group *RouteGroup) RouteFunc(route string, f func(http.ResponseWriter, *http.Request)) {
-	group.routes[route] = http.HandlerFunc(f)
+// The middlewares are only used for this route and run after the group's middlewares.
+func (group *RouteGroup) RouteFunc(route string, f func(http.ResponseWriter, *http.Request), middlewares ...Middleware) {
+	group.root.addRoute(route, applyMiddlewares(http.HandlerFunc(f), middlewares), group)
 }
 
+// optionsRoute collects the routes of a path so that an OPTIONS handler can be generated for it.
+type optionsRoute struct {
+	pattern string
+	group   *RouteGroup
+	// mixed is true if the routes of the path belong to different groups.
+	mixed      bool
+	hasOptions bool
+}
+
+// Mux registers all routes on an [http.ServeMux] and returns it.
+//
+// Every path that only has method specific routes also gets an OPTIONS route
+// that answers with an `Allow` header, so middlewares like CORS can answer
+// preflight requests. Paths that only differ in the names of their wildcards
+// share the OPTIONS route, and it is left out if it would conflict with another route.
+// It uses the middlewares of the group of the path, or only the middlewares of the
+// root router if the path has routes in several groups.
 func (router *Root) Mux() *http.ServeMux {
-	for route, handler := range router.routes {
-		handlerWithMiddlewares := applyMiddlewares(handler, router.middlewares)
-		router.mux.Handle(route, handlerWithMiddlewares)
-	}
+	options := make(map[string]*optionsRoute)
+	shapes := make([]string, 0)
+	methods := make([]string, 0)
+	groupMiddlewares := make(map[*RouteGroup][]Middleware)
 
 	for _, group := range router.groups {
-		middlewares := append(router.middlewares, group.middlewares...)
+		groupMiddlewares[group] = slices.Concat(router.middlewares, group.middlewares)
+	}
+
+	for _, route := range router.routes {
+		method, host, path := parsePattern(route.pattern)
+		middlewares := router.middlewares
+
+		if route.group != nil {
+			path = route.group.prefix + path
+			middlewares = groupMiddlewares[route.group]
+		}
+
+		router.mux.Handle(strings.TrimSpace(method+" "+host+path), applyMiddlewares(route.handler, middlewares))
+
+		if method == "" {
+			continue
+		}
+
+		if !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+
+		shape := host + pathShape(path)
+		pathOptions, ok := options[shape]
 
-		for route, handler := range group.routes {
-			handlerWithMiddlewares := applyMiddlewares(handler, middlewares)
+		if !ok {
+			pathOptions = &optionsRoute{pattern: http.MethodOptions + " " + host + path, group: route.group}
+			options[shape] = pathOptions
+			shapes = append(shapes, shape)
+		}
+
+		pathOptions.mixed = pathOptions.mixed || pathOptions.group != route.group
+		pathOptions.hasOptions = pathOptions.hasOptions || method == http.MethodOptions
+	}
+
+	handler := optionsHandler(router.mux, methods)
+
+	for _, shape := range shapes {
+		pathOptions := options[shape]
 
-			method, host, path := parsePattern(route)
+		if pathOptions.hasOptions {
+			continue
+		}
+
+		middlewares := router.middlewares
 
-			router.mux.Handle(method+" "+host+group.prefix+path, handlerWithMiddlewares)
+		if pathOptions.group != nil && !pathOptions.mixed {
+			middlewares = groupMiddlewares[pathOptions.group]
 		}
+
+		handleUnlessConflicting(router.mux, pathOptions.pattern, applyMiddlewares(handler, middlewares))
 	}
 
 	return router.mux
 }
+
+// handleUnlessConflicting registers a generated route, unless it conflicts with
+// a registered one. [http.ServeMux] only reports conflicts by panicking.
+func handleUnlessConflicting(mux *http.ServeMux, pattern string, handler http.Handler) {
+	defer func() {
+		recover()
+	}()
+
+	mux.Handle(pattern, handler)
+}
+
+// optionsHandler responds to OPTIONS requests with the methods that have a route for the path.
+func optionsHandler(mux *http.ServeMux, methods []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := []string{http.MethodOptions}
+
+		for _, method := range methods {
+			probe := *r
+			probe.Method = method
+
+			if _, pattern := mux.Handler(&probe); pattern != "" {
+				allowed = append(allowed, method)
+			}
+		}
+
+		if slices.Contains(allowed, http.MethodGet) {
+			allowed = append(allowed, http.MethodHead)
+		}
+
+		slices.Sort(allowed)
+
+		w.Header().Set("Allow", strings.Join(slices.Compact(allowed), ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
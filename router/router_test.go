@@ -46,3 +46,177 @@ func TestRouteGroup(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", recorder.Code)
 	}
 }
+
+func TestAutomaticOptionsRoute(t *testing.T) {
+	router := router.NewRootRouter()
+
+	g := router.Group("/group")
+	g.RouteFunc("GET /test", testHandler)
+	g.RouteFunc("POST /test", testHandler)
+
+	mux := router.Mux()
+
+	req := httptest.NewRequest("OPTIONS", "/group/test", nil)
+
+	recorder := httptest.NewRecorder()
+
+	mux.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", recorder.Code)
+	}
+
+	if allow := recorder.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("Unexpected Allow header %q", allow)
+	}
+}
+
+func TestAutomaticOptionsRouteMiddlewares(t *testing.T) {
+	tag := func(value string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Group", value)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	router := router.NewRootRouter()
+
+	a := router.Group("")
+	a.Use(tag("a"))
+	a.RouteFunc("GET /shared", testHandler)
+	a.RouteFunc("GET /own", testHandler)
+	a.RouteFunc("POST /own", testHandler)
+
+	router.RouteFunc("POST /shared", testHandler)
+
+	b := router.Group("")
+	b.Use(tag("b"))
+	b.RouteFunc("PUT /shared", testHandler)
+
+	mux := router.Mux()
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("OPTIONS", "/shared", nil))
+
+	if group := recorder.Header().Get("X-Group"); group != "" {
+		t.Errorf("Expected only the root middlewares, got the middlewares of group %q", group)
+	}
+
+	if allow := recorder.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, POST, PUT" {
+		t.Errorf("Unexpected Allow header %q", allow)
+	}
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("OPTIONS", "/own", nil))
+
+	if group := recorder.Header().Get("X-Group"); group != "a" {
+		t.Errorf("Expected the middlewares of group a, got %q", group)
+	}
+}
+
+func TestAutomaticOptionsRouteOverlappingPaths(t *testing.T) {
+	router := router.NewRootRouter()
+
+	router.RouteFunc("GET /a/{id}", testHandler)
+	router.RouteFunc("POST /a/{name}", testHandler)
+	router.RouteFunc("PUT /a/new", testHandler)
+	router.RouteFunc("GET /a/{id}/x", testHandler)
+	router.RouteFunc("POST /{b}/new/x", testHandler)
+
+	mux := router.Mux()
+
+	tests := map[string]struct {
+		status int
+		allow  string
+	}{
+		"/a/1":     {http.StatusNoContent, "GET, HEAD, OPTIONS, POST"},
+		"/a/new":   {http.StatusNoContent, "GET, HEAD, OPTIONS, POST, PUT"},
+		"/a/1/x":   {http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		"/a/new/x": {http.StatusNoContent, "GET, HEAD, OPTIONS, POST"},
+		// OPTIONS /{b}/new/x conflicts with OPTIONS /a/{id}/x, so it is left out.
+		"/b/new/x": {http.StatusMethodNotAllowed, "POST"},
+	}
+
+	for path, expected := range tests {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest("OPTIONS", path, nil))
+
+		if recorder.Code != expected.status {
+			t.Errorf("Expected status %d for %s, got %d", expected.status, path, recorder.Code)
+		}
+
+		if allow := recorder.Header().Get("Allow"); allow != expected.allow {
+			t.Errorf("Expected Allow header %q for %s, got %q", expected.allow, path, allow)
+		}
+	}
+}
+
+func TestGroupMiddlewaresAreIsolated(t *testing.T) {
+	router := router.NewRootRouter()
+
+	// Enough root middlewares to leave spare capacity in the slice.
+	for range 3 {
+		router.Use(func(next http.Handler) http.Handler { return next })
+	}
+
+	tag := func(value string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Group", value)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	a := router.Group("/a")
+	a.Use(tag("a"))
+	a.RouteFunc("GET /", testHandler)
+
+	b := router.Group("/b")
+	b.Use(tag("b"))
+	b.RouteFunc("GET /", testHandler)
+
+	mux := router.Mux()
+
+	req := httptest.NewRequest("GET", "/a/", nil)
+
+	recorder := httptest.NewRecorder()
+
+	mux.ServeHTTP(recorder, req)
+
+	if group := recorder.Header().Get("X-Group"); group != "a" {
+		t.Errorf("Expected group a, got %q", group)
+	}
+}
+
+func TestRouteMiddlewares(t *testing.T) {
+	router := router.NewRootRouter()
+
+	reject := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}
+
+	g := router.Group("/group")
+	g.RouteFunc("GET /rejected", testHandler, reject)
+	g.RouteFunc("GET /test", testHandler)
+
+	mux := router.Mux()
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/group/rejected", nil))
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/group/test", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", recorder.Code)
+	}
+}